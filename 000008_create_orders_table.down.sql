@@ -1,3 +1,3 @@
-DROP TABLE orders;
-
+DROP TABLE orders;
+
 DROP TYPE order_status;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE sessions (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      uuid NOT NULL,
+    family_id    uuid NOT NULL,
+    token_hash   VARCHAR(64) NOT NULL UNIQUE,
+    expires_at   TIMESTAMP NOT NULL,
+    revoked_at   TIMESTAMP DEFAULT NULL,
+    replaced_by  uuid DEFAULT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_family_id ON sessions (family_id);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
@@ -0,0 +1,10 @@
+-- Postgres cannot drop enum values, so rebuild the type with the original values
+ALTER TYPE order_status RENAME TO order_status_old;
+
+CREATE TYPE order_status AS ENUM ('completed', 'preparing');
+
+ALTER TABLE orders
+    ALTER COLUMN status TYPE order_status
+    USING (CASE WHEN status::text IN ('served', 'completed') THEN 'completed' ELSE 'preparing' END)::order_status;
+
+DROP TYPE order_status_old;
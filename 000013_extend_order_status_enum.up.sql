@@ -0,0 +1,6 @@
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'pending';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'accepted';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'ready';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'served';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'cancelled';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'rejected';
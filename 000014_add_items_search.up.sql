@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE items
+    ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(name, ''))) STORED;
+
+CREATE INDEX idx_items_search_vector ON items USING GIN (search_vector);
+CREATE INDEX idx_items_name_trgm ON items USING GIN (name gin_trgm_ops);
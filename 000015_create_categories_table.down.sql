@@ -0,0 +1,4 @@
+ALTER TABLE items DROP CONSTRAINT IF EXISTS fk_category_id;
+ALTER TABLE items DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE categories;
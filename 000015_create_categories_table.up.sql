@@ -0,0 +1,28 @@
+CREATE TABLE categories (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    vendor_id     uuid NOT NULL,
+    parent_id     uuid DEFAULT NULL,
+    name          VARCHAR(255) NOT NULL,
+    position      INT NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_vendor_id
+    FOREIGN KEY (vendor_id)
+        REFERENCES vendors (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_parent_id
+    FOREIGN KEY (parent_id)
+        REFERENCES categories (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_categories_vendor_id ON categories (vendor_id, position);
+
+ALTER TABLE items
+    ADD COLUMN category_id uuid DEFAULT NULL,
+    ADD CONSTRAINT fk_category_id
+        FOREIGN KEY (category_id)
+            REFERENCES categories (id)
+            ON DELETE SET NULL;
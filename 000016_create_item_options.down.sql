@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS order_item_options;
+
+-- Keep one line per item; lines that only differ by options are dropped
+DELETE FROM cart_items a
+    USING cart_items b
+    WHERE a.cart_id = b.cart_id AND a.item_id = b.item_id AND a.id > b.id;
+ALTER TABLE cart_items DROP CONSTRAINT IF EXISTS uq_cart_items_line;
+ALTER TABLE cart_items DROP CONSTRAINT cart_items_pkey;
+ALTER TABLE cart_items ADD PRIMARY KEY (cart_id, item_id);
+ALTER TABLE cart_items
+    DROP COLUMN IF EXISTS option_ids,
+    DROP COLUMN IF EXISTS id;
+
+DROP TABLE IF EXISTS options;
+DROP TABLE IF EXISTS option_groups;
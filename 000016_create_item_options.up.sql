@@ -0,0 +1,68 @@
+CREATE TABLE option_groups (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    item_id       uuid NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    min_select    INT NOT NULL DEFAULT 0,
+    max_select    INT NOT NULL DEFAULT 1,
+    position      INT NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_selection
+    CHECK (min_select >= 0 AND max_select >= 1 AND min_select <= max_select),
+
+    CONSTRAINT fk_item_id
+    FOREIGN KEY (item_id)
+        REFERENCES items (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_option_groups_item_id ON option_groups (item_id, position);
+
+CREATE TABLE options (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    option_group_id  uuid NOT NULL,
+    name             VARCHAR(255) NOT NULL,
+    price_delta      DECIMAL(10,2) NOT NULL DEFAULT 0,
+    position         INT NOT NULL DEFAULT 0,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_option_group_id
+    FOREIGN KEY (option_group_id)
+        REFERENCES option_groups (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_options_option_group_id ON options (option_group_id, position);
+
+-- A cart line is now an item plus its sorted option ids, so the same item
+-- with different options can sit in one cart
+ALTER TABLE cart_items
+    ADD COLUMN id uuid NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN option_ids uuid[] NOT NULL DEFAULT '{}';
+ALTER TABLE cart_items DROP CONSTRAINT cart_items_pkey;
+ALTER TABLE cart_items ADD PRIMARY KEY (id);
+ALTER TABLE cart_items ADD CONSTRAINT uq_cart_items_line UNIQUE (cart_id, item_id, option_ids);
+
+-- Options chosen on an order line, copied so later menu edits do not change past orders
+CREATE TABLE order_item_options (
+    id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_item_id  uuid NOT NULL,
+    option_id      uuid DEFAULT NULL,
+    group_name     VARCHAR(255) NOT NULL,
+    name           VARCHAR(255) NOT NULL,
+    price_delta    DECIMAL(10,2) NOT NULL,
+
+    CONSTRAINT fk_order_item_id
+    FOREIGN KEY (order_item_id)
+        REFERENCES order_items (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_option_id
+    FOREIGN KEY (option_id)
+        REFERENCES options (id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX idx_order_item_options_order_item_id ON order_item_options (order_item_id);
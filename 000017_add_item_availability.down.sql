@@ -0,0 +1,4 @@
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS chk_stock,
+    DROP COLUMN IF EXISTS stock,
+    DROP COLUMN IF EXISTS is_available;
@@ -0,0 +1,5 @@
+-- stock is NULL for items whose quantity is not tracked
+ALTER TABLE items
+    ADD COLUMN is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN stock INT DEFAULT NULL,
+    ADD CONSTRAINT chk_stock CHECK (stock IS NULL OR stock >= 0);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS vendor_closures;
+DROP TABLE IF EXISTS vendor_hours;
+
+ALTER TABLE vendors
+    DROP COLUMN IF EXISTS paused_until,
+    DROP COLUMN IF EXISTS is_paused,
+    DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,38 @@
+ALTER TABLE vendors
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    ADD COLUMN is_paused BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN paused_until TIMESTAMPTZ DEFAULT NULL;
+
+-- Weekly opening windows in the vendor's timezone. weekday follows Go's time.Weekday (0 = Sunday);
+-- a window whose closes_at is not after opens_at runs past midnight into the next day.
+CREATE TABLE vendor_hours (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    vendor_id     uuid NOT NULL,
+    weekday       SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_at      TIME NOT NULL,
+    closes_at     TIME NOT NULL CHECK (closes_at <> opens_at),
+
+    CONSTRAINT fk_vendor_id
+    FOREIGN KEY (vendor_id)
+        REFERENCES vendors (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_vendor_hours_vendor_id ON vendor_hours (vendor_id, weekday);
+
+-- Whole days the vendor is closed, such as holidays
+CREATE TABLE vendor_closures (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    vendor_id     uuid NOT NULL,
+    starts_on     DATE NOT NULL,
+    ends_on       DATE NOT NULL CHECK (ends_on >= starts_on),
+    reason        VARCHAR(255),
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_vendor_id
+    FOREIGN KEY (vendor_id)
+        REFERENCES vendors (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_vendor_closures_vendor_id ON vendor_closures (vendor_id, ends_on);
@@ -0,0 +1,24 @@
+-- One row per seating at a table; released_at stays NULL while the customer is still seated
+CREATE TABLE table_sessions (
+    id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_id       uuid NOT NULL,
+    customer_id    uuid DEFAULT NULL,
+    seated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    released_at    TIMESTAMP DEFAULT NULL,
+    service_calls  INT NOT NULL DEFAULT 0,
+
+    CONSTRAINT fk_table_id
+    FOREIGN KEY (table_id)
+        REFERENCES tables (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_customer_id
+    FOREIGN KEY (customer_id)
+        REFERENCES users (id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX idx_table_sessions_table_id ON table_sessions (table_id, seated_at);
+-- A table seats one party and a customer sits at one table at a time
+CREATE UNIQUE INDEX uq_table_sessions_open_table ON table_sessions (table_id) WHERE released_at IS NULL;
+CREATE UNIQUE INDEX uq_table_sessions_open_customer ON table_sessions (customer_id) WHERE released_at IS NULL;
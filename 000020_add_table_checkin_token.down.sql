@@ -0,0 +1 @@
+ALTER TABLE tables DROP COLUMN IF EXISTS checkin_nonce;
@@ -0,0 +1,2 @@
+-- Part of the signed check-in token printed on the table; replacing it invalidates old QR codes
+ALTER TABLE tables ADD COLUMN checkin_nonce uuid NOT NULL DEFAULT gen_random_uuid();
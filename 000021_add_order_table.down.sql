@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_orders_table_id;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS chk_table_dine_in,
+    DROP CONSTRAINT IF EXISTS fk_table_id,
+    DROP COLUMN IF EXISTS table_id,
+    DROP COLUMN IF EXISTS order_type;
+
+DROP TYPE IF EXISTS order_type;
@@ -0,0 +1,11 @@
+CREATE TYPE order_type AS ENUM ('dine_in', 'takeaway', 'delivery');
+
+-- table_id is set for dine-in orders so the staff know where to bring the food;
+-- it is cleared rather than the order deleted when the table is removed
+ALTER TABLE orders
+    ADD COLUMN order_type order_type NOT NULL DEFAULT 'takeaway',
+    ADD COLUMN table_id uuid DEFAULT NULL,
+    ADD CONSTRAINT fk_table_id FOREIGN KEY (table_id) REFERENCES tables (id) ON DELETE SET NULL,
+    ADD CONSTRAINT chk_table_dine_in CHECK (table_id IS NULL OR order_type = 'dine_in');
+
+CREATE INDEX idx_orders_table_id ON orders (table_id) WHERE table_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reservations;
+DROP TYPE IF EXISTS reservation_status;
@@ -0,0 +1,36 @@
+-- btree_gist lets the exclusion constraint compare table ids alongside the time ranges
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TYPE reservation_status AS ENUM ('booked', 'cancelled', 'no_show');
+
+CREATE TABLE reservations (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_id     uuid NOT NULL,
+    customer_id  uuid NOT NULL,
+    party_size   INT NOT NULL,
+    starts_at    TIMESTAMPTZ NOT NULL,
+    ends_at      TIMESTAMPTZ NOT NULL,
+    status       reservation_status NOT NULL DEFAULT 'booked',
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_table_id
+    FOREIGN KEY (table_id)
+        REFERENCES tables (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_customer_id
+    FOREIGN KEY (customer_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT chk_party_size CHECK (party_size > 0),
+    CONSTRAINT chk_reservation_period CHECK (ends_at > starts_at),
+
+    -- Only live bookings hold a table; cancelled and no-show slots can be booked again
+    CONSTRAINT excl_reservations_overlap
+    EXCLUDE USING gist (table_id WITH =, tstzrange(starts_at, ends_at) WITH &&)
+        WHERE (status = 'booked')
+);
+
+CREATE INDEX idx_reservations_customer_id ON reservations (customer_id, starts_at);
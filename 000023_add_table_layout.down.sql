@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_tables_vendor_zone;
+
+ALTER TABLE tables
+    DROP CONSTRAINT IF EXISTS chk_position,
+    DROP CONSTRAINT IF EXISTS chk_capacity,
+    DROP COLUMN IF EXISTS position_y,
+    DROP COLUMN IF EXISTS position_x,
+    DROP COLUMN IF EXISTS zone,
+    DROP COLUMN IF EXISTS capacity;
@@ -0,0 +1,10 @@
+-- position_x/position_y are grid coordinates on the vendor's floor plan
+ALTER TABLE tables
+    ADD COLUMN capacity INT NOT NULL DEFAULT 2,
+    ADD COLUMN zone VARCHAR(100) NOT NULL DEFAULT 'indoor',
+    ADD COLUMN position_x INT NOT NULL DEFAULT 0,
+    ADD COLUMN position_y INT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT chk_capacity CHECK (capacity > 0),
+    ADD CONSTRAINT chk_position CHECK (position_x >= 0 AND position_y >= 0);
+
+CREATE INDEX idx_tables_vendor_zone ON tables (vendor_id, zone);
@@ -0,0 +1,28 @@
+-- One row per call for a waiter; acknowledged_at stays NULL until a member of staff takes it
+CREATE TABLE service_calls (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_id         uuid NOT NULL,
+    customer_id      uuid DEFAULT NULL,
+    requested_at     TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    acknowledged_at  TIMESTAMPTZ DEFAULT NULL,
+    acknowledged_by  uuid DEFAULT NULL,
+
+    CONSTRAINT fk_table_id
+    FOREIGN KEY (table_id)
+        REFERENCES tables (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_customer_id
+    FOREIGN KEY (customer_id)
+        REFERENCES users (id)
+        ON DELETE SET NULL,
+
+    CONSTRAINT fk_acknowledged_by
+    FOREIGN KEY (acknowledged_by)
+        REFERENCES users (id)
+        ON DELETE SET NULL
+);
+
+-- A table has at most one call waiting at a time
+CREATE UNIQUE INDEX uq_service_calls_open ON service_calls (table_id) WHERE acknowledged_at IS NULL;
+CREATE INDEX idx_service_calls_table_id ON service_calls (table_id, requested_at);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS payments;
+DROP TYPE IF EXISTS payment_status;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS paid_at,
+    DROP COLUMN IF EXISTS prepaid;
@@ -0,0 +1,31 @@
+-- Prepaid orders are held back from the kitchen until paid_at is set
+ALTER TABLE orders
+    ADD COLUMN prepaid BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN paid_at TIMESTAMPTZ DEFAULT NULL;
+
+CREATE TYPE payment_status AS ENUM ('authorized', 'captured', 'failed', 'partially_refunded', 'refunded');
+
+CREATE TABLE payments (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id         uuid NOT NULL,
+    provider         VARCHAR(50) NOT NULL,
+    reference        VARCHAR(255) DEFAULT NULL,
+    amount           DECIMAL(10,2) NOT NULL,
+    refunded_amount  DECIMAL(10,2) NOT NULL DEFAULT 0,
+    status           payment_status NOT NULL,
+    failure_reason   TEXT DEFAULT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_order_id
+    FOREIGN KEY (order_id)
+        REFERENCES orders (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT uq_payments_reference UNIQUE (provider, reference),
+    CONSTRAINT chk_refunded_amount CHECK (refunded_amount >= 0 AND refunded_amount <= amount)
+);
+
+-- An order holds at most one payment that has not failed or been refunded in full
+CREATE UNIQUE INDEX uq_payments_live_order ON payments (order_id)
+    WHERE status IN ('authorized', 'captured', 'partially_refunded');
@@ -0,0 +1,25 @@
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_currency;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE carts DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS fk_vendor_currency,
+    DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE vendors
+    DROP CONSTRAINT IF EXISTS uq_vendors_id_currency,
+    DROP CONSTRAINT IF EXISTS chk_vendors_currency,
+    DROP COLUMN IF EXISTS currency;
+
+-- Amounts with a third decimal place are rounded
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE DECIMAL(10,2),
+    ALTER COLUMN refunded_amount TYPE DECIMAL(10,2);
+ALTER TABLE order_item_options ALTER COLUMN price_delta TYPE DECIMAL(10,2);
+ALTER TABLE order_items ALTER COLUMN price TYPE DECIMAL(10,2);
+ALTER TABLE orders ALTER COLUMN total_order_cost TYPE DECIMAL(10,2);
+ALTER TABLE carts ALTER COLUMN total_price TYPE DECIMAL(10,2);
+ALTER TABLE options ALTER COLUMN price_delta TYPE DECIMAL(10,2);
+ALTER TABLE items ALTER COLUMN price TYPE DECIMAL(10,2);
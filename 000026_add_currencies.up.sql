@@ -0,0 +1,46 @@
+-- Prices are kept to three decimal places so currencies quoted in thousandths fit
+ALTER TABLE items ALTER COLUMN price TYPE DECIMAL(12,3);
+ALTER TABLE options ALTER COLUMN price_delta TYPE DECIMAL(12,3);
+ALTER TABLE carts ALTER COLUMN total_price TYPE DECIMAL(12,3);
+ALTER TABLE orders ALTER COLUMN total_order_cost TYPE DECIMAL(12,3);
+ALTER TABLE order_items ALTER COLUMN price TYPE DECIMAL(12,3);
+ALTER TABLE order_item_options ALTER COLUMN price_delta TYPE DECIMAL(12,3);
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE DECIMAL(12,3),
+    ALTER COLUMN refunded_amount TYPE DECIMAL(12,3);
+
+-- Each vendor sells in one ISO 4217 currency
+ALTER TABLE vendors
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD',
+    ADD CONSTRAINT chk_vendors_currency CHECK (currency ~ '^[A-Z]{3}$'),
+    ADD CONSTRAINT uq_vendors_id_currency UNIQUE (id, currency);
+
+-- Items carry their vendor's currency; the key keeps them from drifting apart
+ALTER TABLE items ADD COLUMN currency CHAR(3);
+UPDATE items SET currency = vendors.currency FROM vendors WHERE vendors.id = items.vendor_id;
+ALTER TABLE items
+    ALTER COLUMN currency SET NOT NULL,
+    ADD CONSTRAINT fk_vendor_currency FOREIGN KEY (vendor_id, currency) REFERENCES vendors (id, currency) ON DELETE CASCADE;
+
+-- A cart takes the currency of the vendor it is bound to, NULL while it holds nothing
+ALTER TABLE carts ADD COLUMN currency CHAR(3) DEFAULT NULL;
+UPDATE carts SET currency = vendors.currency FROM vendors WHERE vendors.id = carts.vendor_id;
+
+-- Orders keep the currency they were placed in
+ALTER TABLE orders ADD COLUMN currency CHAR(3);
+UPDATE orders SET currency = vendors.currency FROM vendors WHERE vendors.id = orders.vendor_id;
+ALTER TABLE orders ALTER COLUMN currency SET NOT NULL;
+
+-- Shown prices are converted to the user's currency when a rate is known
+ALTER TABLE users ADD COLUMN preferred_currency CHAR(3) DEFAULT NULL;
+
+-- Static rates for approximate display prices: 1 unit of base_currency = rate units of quote_currency
+CREATE TABLE exchange_rates (
+    base_currency   CHAR(3) NOT NULL,
+    quote_currency  CHAR(3) NOT NULL,
+    rate            NUMERIC(20,10) NOT NULL,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (base_currency, quote_currency),
+    CONSTRAINT chk_exchange_rate CHECK (rate > 0 AND base_currency <> quote_currency)
+);
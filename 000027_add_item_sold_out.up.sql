@@ -0,0 +1,4 @@
+-- Marks items switched off because their stock ran out, as opposed to switched off by the vendor;
+-- only those come back on sale when stock is returned
+ALTER TABLE items ADD COLUMN sold_out BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE items SET sold_out = TRUE WHERE stock = 0 AND NOT is_available;
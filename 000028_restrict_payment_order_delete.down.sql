@@ -0,0 +1,3 @@
+ALTER TABLE payments
+    DROP CONSTRAINT fk_order_id,
+    ADD CONSTRAINT fk_order_id FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE;
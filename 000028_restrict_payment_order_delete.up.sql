@@ -0,0 +1,4 @@
+-- Payment records outlive mistakes in the orders table, an order with payments cannot be deleted
+ALTER TABLE payments
+    DROP CONSTRAINT fk_order_id,
+    ADD CONSTRAINT fk_order_id FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE RESTRICT;
@@ -0,0 +1 @@
+-- The totals clients sent before are not kept, nothing to restore
@@ -0,0 +1,2 @@
+-- Order totals are computed from the order lines; replace totals that clients sent
+UPDATE orders SET total_order_cost = COALESCE((SELECT SUM(order_items.price * order_items.quantity) FROM order_items WHERE order_items.order_id = orders.id), 0);
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// AuthMiddleware validates the access token sent as a bearer token or in the
+// accessToken cookie and stores the authenticated user in the request context
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString := tokenFromRequest(r)
+		if tokenString == "" {
+			utils.HandelError(w, http.StatusUnauthorized, "Missing access token")
+			return
+		}
+
+		claims, err := parseAccessToken(tokenString)
+		if err != nil {
+			if errors.Is(err, errAccessTokenExpired) {
+				utils.HandelError(w, http.StatusUnauthorized, "Access token expired")
+				return
+			}
+			utils.HandelError(w, http.StatusUnauthorized, "Invalid access token")
+			return
+		}
+
+		// Tokens bound to a session stop working once that session is logged out or revoked
+		if claims.SessionID != uuid.Nil {
+			active, err := sessionFamilyActive(claims.SessionID)
+			if err != nil {
+				utils.HandelError(w, http.StatusInternalServerError, "Error checking session: "+err.Error())
+				return
+			}
+			if !active {
+				utils.HandelError(w, http.StatusUnauthorized, "Session has been revoked")
+				return
+			}
+		}
+
+		user, err := loadUserWithRoles(claims.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.HandelError(w, http.StatusUnauthorized, "User not found")
+				return
+			}
+			utils.HandelError(w, http.StatusInternalServerError, "Error fetching user: "+err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), user)))
+	})
+}
+
+// ContextWithUser returns a copy of ctx carrying the authenticated user
+func ContextWithUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// CurrentUser returns the user stored in the request context by AuthMiddleware
+func CurrentUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(userContextKey).(models.User)
+	return user, ok
+}
+
+// tokenFromRequest reads the bearer token first and falls back to the accessToken cookie
+func tokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if cookie, err := r.Cookie("accessToken"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// accessClaims are the claims the API relies on in an access token
+type accessClaims struct {
+	UserID uuid.UUID
+	// SessionID is the session family the token was issued for, uuid.Nil for tokens issued without one
+	SessionID uuid.UUID
+}
+
+var errAccessTokenExpired = errors.New("access token expired")
+
+// parseAccessToken verifies an access token and returns its claims.
+// Expired but otherwise valid tokens return their claims together with errAccessTokenExpired.
+func parseAccessToken(tokenString string) (accessClaims, error) {
+	var claims accessClaims
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	expired := false
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if token == nil || !errors.As(err, &validationErr) || validationErr.Errors != jwt.ValidationErrorExpired {
+			return claims, err
+		}
+		expired = true
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return claims, errors.New("invalid token claims")
+	}
+	subject, ok := mapClaims["user_id"].(string)
+	if !ok {
+		return claims, errors.New("token has no user_id claim")
+	}
+	if claims.UserID, err = uuid.Parse(subject); err != nil {
+		return claims, err
+	}
+	if sid, ok := mapClaims["sid"].(string); ok {
+		if claims.SessionID, err = uuid.Parse(sid); err != nil {
+			return claims, err
+		}
+	}
+
+	if expired {
+		return claims, errAccessTokenExpired
+	}
+	return claims, nil
+}
+
+// loadUserWithRoles fetches a user together with the roles linked through user_roles
+func loadUserWithRoles(userID uuid.UUID) (models.User, error) {
+	var user models.User
+	query, args, err := QB.Select(strings.Join(user_columns, ", ")).
+		From("users").
+		Where(squirrel.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return user, err
+	}
+	if err := db.Get(&user, query, args...); err != nil {
+		return user, err
+	}
+
+	query, args, err = QB.Select("roles.id", "roles.name").
+		From("roles").
+		Join("user_roles ON user_roles.role_id = roles.id").
+		Where(squirrel.Eq{"user_roles.user_id": userID}).
+		OrderBy("roles.id").
+		ToSql()
+	if err != nil {
+		return user, err
+	}
+	if err := db.Select(&user.Roles, query, args...); err != nil {
+		return user, err
+	}
+	return user, nil
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"intership/utils"
+	"net/http"
+)
+
+// RequireRoles only lets the request through when the authenticated user holds one of the given roles.
+// It must be registered after AuthMiddleware.
+func RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := CurrentUser(r)
+			if !ok {
+				utils.HandelError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			if !user.HasRole(roles...) {
+				utils.HandelError(w, http.StatusForbidden, "You are not allowed to access this resource")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSelfOrRoles lets users act on their own record, identified by the given path value,
+// and otherwise falls back to RequireRoles
+func RequireSelfOrRoles(param string, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		byRole := RequireRoles(roles...)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := CurrentUser(r)
+			if ok && user.ID.String() == r.PathValue(param) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			byRole.ServeHTTP(w, r)
+		})
+	}
+}
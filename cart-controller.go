@@ -1,189 +1,355 @@
-package controllers
-
-import (
-	"fmt"
-	"intership/models"
-	"intership/utils"
-	"net/http"
-	"strconv"
-	"strings"
-
-	"github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
-)
-
-var cartColumns = []string{
-	"id",
-	"total_price",
-	"quantity",
-	"vendor_id",
-	"created_at",
-	"updated_at",
-}
-
-// IndexCartHandler handles GET requests to fetch all carts
-func IndexCartHandler(w http.ResponseWriter, r *http.Request) {
-	var carts []models.Cart
-	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).From("carts").ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Select(&carts, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, carts)
-}
-
-// ShowCartHandler handles GET requests to fetch a single cart by ID
-func ShowCartHandler(w http.ResponseWriter, r *http.Request) {
-	var cart models.Cart
-	id := r.PathValue("id")
-	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).From("carts").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&cart, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, cart)
-}
-
-// CreateCartHandler handles POST requests to create a new cart
-func CreateCartHandler(w http.ResponseWriter, r *http.Request) {
-	var cart models.Cart
-	if r.FormValue("id") == "" || r.FormValue("total_price") == "" || r.FormValue("quantity") == "" {
-		utils.HandelError(w, http.StatusBadRequest, "User ID, total price, and quantity are required")
-		return
-	}
-
-	cartID, err := uuid.Parse(r.FormValue("id"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid id format")
-		return
-	}
-
-	totalPrice, err := strconv.ParseFloat(r.FormValue("total_price"), 64)
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid total_price format")
-		return
-	}
-
-	quantity, err := strconv.Atoi(r.FormValue("quantity"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid quantity format")
-		return
-	}
-
-	cart.ID = cartID
-	cart.TotalPrice = totalPrice
-	cart.Quantity = quantity
-
-	// Optional vendor ID
-	if r.FormValue("vendor_id") != "" {
-		vendorID, err := uuid.Parse(r.FormValue("vendor_id"))
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
-			return
-		}
-		cart.VendorID = vendorID
-	}
-
-	query, args, err := QB.Insert("carts").
-		Columns("id", "total_price", "quantity", "vendor_id", "created_at", "updated_at").
-		Values(cart.ID, cart.TotalPrice, cart.Quantity, cart.VendorID, "NOW()", "NOW()").
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartColumns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&cart); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusCreated, cart)
-}
-
-// UpdateCartHandler handles PUT requests to update an existing cart
-func UpdateCartHandler(w http.ResponseWriter, r *http.Request) {
-	var cart models.Cart
-	id := r.PathValue("id")
-
-	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).From("carts").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&cart, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if r.FormValue("total_price") != "" {
-		totalPrice, err := strconv.ParseFloat(r.FormValue("total_price"), 64)
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid total_price format")
-			return
-		}
-		cart.TotalPrice = totalPrice
-	}
-	if r.FormValue("quantity") != "" {
-		quantity, err := strconv.Atoi(r.FormValue("quantity"))
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid quantity format")
-			return
-		}
-		cart.Quantity = quantity
-	}
-	if r.FormValue("vendor_id") != "" {
-		vendorID, err := uuid.Parse(r.FormValue("vendor_id"))
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
-			return
-		}
-		cart.VendorID = vendorID
-	}
-
-	query, args, err = QB.Update("carts").
-		Set("total_price", cart.TotalPrice).
-		Set("quantity", cart.Quantity).
-		Set("vendor_id", cart.VendorID).
-		Where(squirrel.Eq{"id": cart.ID}).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartColumns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&cart); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, cart)
-}
-
-// DeleteCartHandler handles DELETE requests to remove a cart
-func DeleteCartHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	query, args, err := QB.Delete("carts").Where("id=?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if _, err := db.Exec(query, args...); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	utils.SendJSONResponse(w, http.StatusOK, "Cart deleted")
-}
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var cartColumns = []string{
+	"id",
+	"total_price",
+	"quantity",
+	"vendor_id",
+	"currency",
+	"created_at",
+	"updated_at",
+}
+
+var cartLineColumns = []string{
+	"cart_items.id",
+	"cart_items.item_id",
+	"items.name",
+	cartLinePriceSQL + " AS price",
+	"items.currency",
+	fmt.Sprintf("CASE WHEN NULLIF(items.img, '') IS NOT NULL THEN FORMAT('%s/%%s', items.img) ELSE NULL END AS img", Domain),
+	"cart_items.quantity",
+	"(" + cartLinePriceSQL + ") * cart_items.quantity AS subtotal",
+	"cart_items.option_ids",
+}
+
+var cartListSpec = listSpec{
+	Sortable: map[string]string{
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+		"total_price": "total_price",
+		"quantity":    "quantity",
+	},
+	Filterable:  map[string]string{"vendor_id": "vendor_id"},
+	DefaultSort: "-updated_at",
+	TieBreaker:  []string{"id"},
+}
+
+// Cart totals derived from the cart's items at their current prices
+const (
+	// Unit price of a cart line: the item price plus the price deltas of its chosen options
+	cartLinePriceSQL  = "items.price + COALESCE((SELECT SUM(options.price_delta) FROM options WHERE options.id = ANY(cart_items.option_ids)), 0)"
+	cartTotalPriceSQL = "COALESCE((SELECT SUM((" + cartLinePriceSQL + ") * cart_items.quantity) FROM cart_items JOIN items ON items.id = cart_items.item_id WHERE cart_items.cart_id = carts.id), 0)"
+	cartQuantitySQL   = "COALESCE((SELECT SUM(cart_items.quantity) FROM cart_items WHERE cart_items.cart_id = carts.id), 0)"
+	// An emptied cart is released from its vendor and the vendor's currency
+	cartVendorSQL   = "CASE WHEN EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id) THEN carts.vendor_id ELSE NULL END"
+	cartCurrencySQL = "CASE WHEN EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id) THEN carts.currency ELSE NULL END"
+)
+
+// recalculateCart recomputes a cart's total_price and quantity from its cart_items and current item prices,
+// clearing vendor_id and currency once the cart is empty
+func recalculateCart(exec sqlx.Execer, cartID uuid.UUID) error {
+	query, args, err := QB.Update("carts").
+		Set("total_price", squirrel.Expr(cartTotalPriceSQL)).
+		Set("quantity", squirrel.Expr(cartQuantitySQL)).
+		Set("vendor_id", squirrel.Expr(cartVendorSQL)).
+		Set("currency", squirrel.Expr(cartCurrencySQL)).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": cartID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = exec.Exec(query, args...)
+	return err
+}
+
+// recalculateCartsWithItem refreshes every cart holding the item, used when the item's price changes
+func recalculateCartsWithItem(exec sqlx.Execer, itemID uuid.UUID) error {
+	query, args, err := QB.Update("carts").
+		Set("total_price", squirrel.Expr(cartTotalPriceSQL)).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Expr("id IN (SELECT cart_id FROM cart_items WHERE item_id = ?)", itemID)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = exec.Exec(query, args...)
+	return err
+}
+
+// recalculateCartsWithOption refreshes every cart with a line using the option, used when its price delta changes
+func recalculateCartsWithOption(exec sqlx.Execer, optionID uuid.UUID) error {
+	query, args, err := QB.Update("carts").
+		Set("total_price", squirrel.Expr(cartTotalPriceSQL)).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Expr("id IN (SELECT cart_id FROM cart_items WHERE ? = ANY(option_ids))", optionID)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = exec.Exec(query, args...)
+	return err
+}
+
+// IndexCartHandler handles GET requests to fetch all carts
+func IndexCartHandler(w http.ResponseWriter, r *http.Request) {
+	var carts []models.Cart
+	selectQuery, ok := scopeByVendor(w, r, QB.Select(strings.Join(cartColumns, ", ")).From("carts"), "vendor_id")
+	if !ok {
+		return
+	}
+	meta, ok := paginate(w, r, selectQuery, cartListSpec, &carts)
+	if !ok {
+		return
+	}
+	sendList(w, meta, carts)
+}
+
+// ShowCartHandler handles GET requests to fetch a single cart by ID
+// Pass include=items to embed the cart lines and vendor summary
+func ShowCartHandler(w http.ResponseWriter, r *http.Request) {
+	showCart(w, r.PathValue("id"), r.URL.Query().Get("include") == "items")
+}
+
+// ShowMyCartHandler handles GET requests for the logged-in user's cart, always including its items
+func ShowMyCartHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		utils.HandelError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	// carts.id is the owning user's id
+	showCart(w, user.ID.String(), true)
+}
+
+func showCart(w http.ResponseWriter, id string, includeItems bool) {
+	var cart models.Cart
+	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).From("carts").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&cart, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Cart not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if includeItems {
+		if err := loadCartDetails(&cart); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error fetching cart items: "+err.Error())
+			return
+		}
+	}
+	models.ApplyCurrency(&cart)
+	utils.SendJSONResponse(w, http.StatusOK, cart)
+}
+
+// loadCartDetails fills the cart lines, joined with their items, and the vendor summary
+func loadCartDetails(cart *models.Cart) error {
+	cart.Items = []models.CartLine{}
+	query, args, err := QB.Select(cartLineColumns...).
+		From("cart_items").
+		Join("items ON items.id = cart_items.item_id").
+		Where(squirrel.Eq{"cart_items.cart_id": cart.ID}).
+		OrderBy("items.name").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := db.Select(&cart.Items, query, args...); err != nil {
+		return err
+	}
+	if err := loadCartLineOptions(cart.Items); err != nil {
+		return err
+	}
+
+	if cart.VendorID == nil {
+		return nil
+	}
+	var vendor models.Vendor
+	query, args, err = QB.Select(strings.Join(vendor_columns, ", ")).
+		From("vendors").
+		Where(squirrel.Eq{"id": *cart.VendorID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := db.Get(&vendor, query, args...); err != nil {
+		return err
+	}
+	cart.Vendor = &vendor
+	return nil
+}
+
+// CreateCartHandler handles POST requests to create a new cart
+func CreateCartHandler(w http.ResponseWriter, r *http.Request) {
+	var cart models.Cart
+	if rejectCartTotals(w, r) {
+		return
+	}
+
+	// A cart shares its owner's id; only admins may create one for another user
+	cartID, ok := actingCustomer(w, r, "id")
+	if !ok {
+		return
+	}
+
+	// Totals start empty and are maintained from cart_items
+	cart.ID = cartID
+
+	// Optional vendor ID
+	if r.FormValue("vendor_id") != "" {
+		vendorID, err := uuid.Parse(r.FormValue("vendor_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+			return
+		}
+		cart.VendorID = &vendorID
+	}
+
+	query, args, err := QB.Insert("carts").
+		Columns("id", "total_price", "quantity", "vendor_id", "currency", "created_at", "updated_at").
+		Values(cart.ID, cart.TotalPrice, cart.Quantity, cart.VendorID, squirrel.Expr("(SELECT currency FROM vendors WHERE id = ?)", cart.VendorID), "NOW()", "NOW()").
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := db.QueryRowx(query, args...).StructScan(&cart); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	models.ApplyCurrency(&cart)
+	utils.SendJSONResponse(w, http.StatusCreated, cart)
+}
+
+// UpdateCartHandler handles PUT requests to update an existing cart
+func UpdateCartHandler(w http.ResponseWriter, r *http.Request) {
+	var cart models.Cart
+	id := r.PathValue("id")
+
+	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).From("carts").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&cart, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if rejectCartTotals(w, r) {
+		return
+	}
+	if r.FormValue("vendor_id") != "" {
+		vendorID, err := uuid.Parse(r.FormValue("vendor_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+			return
+		}
+		// The vendor follows the cart's items, it can only be chosen while the cart is empty
+		if cart.Quantity > 0 && (cart.VendorID == nil || *cart.VendorID != vendorID) {
+			utils.HandelError(w, http.StatusConflict, "Cart already contains items from another vendor")
+			return
+		}
+		cart.VendorID = &vendorID
+	}
+
+	query, args, err = QB.Update("carts").
+		Set("vendor_id", cart.VendorID).
+		Set("currency", squirrel.Expr("(SELECT currency FROM vendors WHERE id = ?)", cart.VendorID)).
+		Where(squirrel.Eq{"id": cart.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := db.QueryRowx(query, args...).StructScan(&cart); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	models.ApplyCurrency(&cart)
+	utils.SendJSONResponse(w, http.StatusOK, cart)
+}
+
+// DeleteCartHandler handles DELETE requests to remove a cart
+func DeleteCartHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query, args, err := QB.Delete("carts").Where("id=?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := db.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, "Cart deleted")
+}
+
+// loadCartLineOptions attaches the chosen options to each cart line
+func loadCartLineOptions(lines []models.CartLine) error {
+	var ids []string
+	for _, line := range lines {
+		ids = append(ids, line.OptionIDs...)
+	}
+	byID := map[string]models.Option{}
+	if len(ids) > 0 {
+		var options []models.Option
+		query, args, err := QB.Select(optionColumns...).From("options").Where(squirrel.Eq{"id": ids}).ToSql()
+		if err != nil {
+			return err
+		}
+		if err := db.Select(&options, query, args...); err != nil {
+			return err
+		}
+		for _, option := range options {
+			byID[option.ID.String()] = option
+		}
+	}
+	for i := range lines {
+		lines[i].Options = []models.Option{}
+		for _, id := range lines[i].OptionIDs {
+			// Options deleted from the menu no longer count towards the line
+			if option, ok := byID[id]; ok {
+				lines[i].Options = append(lines[i].Options, option)
+			}
+		}
+	}
+	return nil
+}
+
+// rejectCartTotals refuses requests that try to write the computed cart totals
+func rejectCartTotals(w http.ResponseWriter, r *http.Request) bool {
+	if r.FormValue("total_price") != "" || r.FormValue("quantity") != "" {
+		utils.HandelError(w, http.StatusBadRequest, "total_price and quantity are computed from the cart items and cannot be set")
+		return true
+	}
+	return false
+}
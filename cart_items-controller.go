@@ -1,162 +1,373 @@
-package controllers
-
-import (
-	"fmt"
-	"intership/models"
-	"intership/utils"
-	"net/http"
-	"strings"
-
-	_"github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
-)
-
-var cartItemColumns = []string{
-	"cart_id",
-	"item_id",
-	"quantity",
-}
-
-// IndexCartItemsHandler handles GET requests to fetch all cart items
-func IndexCartItemsHandler(w http.ResponseWriter, r *http.Request) {
-	var cartItems []models.CartItem
-	query, args, err := QB.Select(strings.Join(cartItemColumns, ", ")).From("cart_items").ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Select(&cartItems, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, cartItems)
-}
-
-// ShowCartItemHandler handles GET requests to fetch a single cart item by cart_id and item_id
-func ShowCartItemHandler(w http.ResponseWriter, r *http.Request) {
-	var cartItem models.CartItem
-	cartID := r.PathValue("cart_id")
-	itemID := r.PathValue("item_id")
-
-	query, args, err := QB.Select(strings.Join(cartItemColumns, ", ")).
-		From("cart_items").
-		Where("cart_id = ? AND item_id = ?", cartID, itemID).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&cartItem, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, cartItem)
-}
-
-// CreateCartItemHandler handles POST requests to create a new cart item
-func CreateCartItemHandler(w http.ResponseWriter, r *http.Request) {
-	var cartItem models.CartItem
-	if r.FormValue("cart_id") == "" || r.FormValue("item_id") == "" || r.FormValue("quantity") == "" {
-		utils.HandelError(w, http.StatusBadRequest, "Cart ID, Item ID, and Quantity are required")
-		return
-	}
-
-	cartID, err := uuid.Parse(r.FormValue("cart_id"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid cart ID format")
-		return
-	}
-
-	itemID, err := uuid.Parse(r.FormValue("item_id"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid item ID format")
-		return
-	}
-
-	quantity := r.FormValue("quantity")
-	cartItem.CartID = cartID
-	cartItem.ItemID = itemID
-	cartItem.Quantity = utils.ParseQuantity(quantity)
-
-	query, args, err := QB.Insert("cart_items").
-		Columns("cart_id", "item_id", "quantity").
-		Values(cartItem.CartID, cartItem.ItemID, cartItem.Quantity).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartItemColumns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	if err := db.QueryRowx(query, args...).StructScan(&cartItem); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusCreated, cartItem)
-}
-
-// UpdateCartItemHandler handles PUT requests to update an existing cart item
-func UpdateCartItemHandler(w http.ResponseWriter, r *http.Request) {
-	var cartItem models.CartItem
-	cartID := r.PathValue("cart_id")
-	itemID := r.PathValue("item_id")
-
-	query, args, err := QB.Select(strings.Join(cartItemColumns, ", ")).
-		From("cart_items").
-		Where("cart_id = ? AND item_id = ?", cartID, itemID).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&cartItem, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	// Update quantity if provided
-	if r.FormValue("quantity") != "" {
-		quantity := r.FormValue("quantity")
-		cartItem.Quantity = utils.ParseQuantity(quantity)
-	}
-
-	query, args, err = QB.Update("cart_items").
-		Set("quantity", cartItem.Quantity).
-		Where("cart_id = ? AND item_id = ?", cartItem.CartID, cartItem.ItemID).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartItemColumns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&cartItem); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart item: "+err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, cartItem)
-}
-
-// DeleteCartItemHandler handles DELETE requests to remove a cart item
-func DeleteCartItemHandler(w http.ResponseWriter, r *http.Request) {
-	cartID := r.PathValue("cart_id")
-	itemID := r.PathValue("item_id")
-
-	query, args, err := QB.Delete("cart_items").
-		Where("cart_id = ? AND item_id = ?", cartID, itemID).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error building delete query: "+err.Error())
-		return
-	}
-
-	_, err = db.Exec(query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error deleting cart item: "+err.Error())
-		return
-	}
-
-	utils.SendJSONResponse(w, http.StatusOK, "Cart item deleted")
-}
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var cartItemColumns = []string{
+	"id",
+	"cart_id",
+	"item_id",
+	"quantity",
+	"option_ids",
+}
+
+var cartItemListSpec = listSpec{
+	Sortable:   map[string]string{"quantity": "quantity"},
+	Filterable: map[string]string{"cart_id": "cart_id", "item_id": "item_id"},
+	TieBreaker: []string{"cart_id", "id"},
+}
+
+var (
+	errCartVendorMismatch   = errors.New("cart already contains items from another vendor")
+	errCartCurrencyMismatch = errors.New("cart already contains items priced in another currency")
+)
+
+// assignCartVendor locks the cart and binds it to the vendor and currency of the item being added.
+// A cart holding another vendor's items, or items in another currency, is rejected, or emptied
+// first when replace is set.
+func assignCartVendor(tx *sqlx.Tx, cartID, itemID uuid.UUID, replace bool) error {
+	var cart models.Cart
+	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).
+		From("carts").
+		Where(squirrel.Eq{"id": cartID}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.Get(&cart, query, args...); err != nil {
+		return err
+	}
+
+	var item models.Item
+	query, args, err = QB.Select("vendor_id", "currency").From("items").Where(squirrel.Eq{"id": itemID}).ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.Get(&item, query, args...); err != nil {
+		return err
+	}
+
+	sameVendor := cart.VendorID != nil && *cart.VendorID == item.VendorID
+	sameCurrency := cart.Currency != nil && *cart.Currency == item.Currency
+	if sameVendor && sameCurrency {
+		return nil
+	}
+	if cart.Quantity > 0 && (!sameVendor || cart.Currency != nil) {
+		if !replace {
+			if !sameVendor {
+				return errCartVendorMismatch
+			}
+			return errCartCurrencyMismatch
+		}
+		query, args, err = QB.Delete("cart_items").Where(squirrel.Eq{"cart_id": cartID}).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	query, args, err = QB.Update("carts").
+		Set("vendor_id", item.VendorID).
+		Set("currency", item.Currency).
+		Where(squirrel.Eq{"id": cartID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+// checkCartItemStock rejects a cart change that would hold more of an item than can be ordered.
+// quantity is the new quantity of the line; the item's other lines in the cart, except exceptLine, are added to it.
+func checkCartItemStock(w http.ResponseWriter, tx *sqlx.Tx, cartID, itemID, exceptLine uuid.UUID, quantity int) bool {
+	var inCart int
+	query, args, err := QB.Select("COALESCE(SUM(quantity), 0)").
+		From("cart_items").
+		Where(squirrel.Eq{"cart_id": cartID, "item_id": itemID}).
+		Where(squirrel.NotEq{"id": exceptLine}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return false
+	}
+	if err := tx.Get(&inCart, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking item stock: "+err.Error())
+		return false
+	}
+
+	var unavailable itemUnavailableError
+	if err := checkItemAvailable(tx, itemID, inCart+quantity); err != nil {
+		if errors.As(err, &unavailable) {
+			utils.HandelError(w, http.StatusConflict, err.Error())
+			return false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking item stock: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// IndexCartItemsHandler handles GET requests to fetch all cart items
+func IndexCartItemsHandler(w http.ResponseWriter, r *http.Request) {
+	var cartItems []models.CartItem
+	meta, ok := paginate(w, r, QB.Select(strings.Join(cartItemColumns, ", ")).From("cart_items"), cartItemListSpec, &cartItems)
+	if !ok {
+		return
+	}
+	sendList(w, meta, cartItems)
+}
+
+// ShowCartItemHandler handles GET requests to fetch a single cart line by cart_id and line id
+func ShowCartItemHandler(w http.ResponseWriter, r *http.Request) {
+	var cartItem models.CartItem
+	cartID := r.PathValue("cart_id")
+	id := r.PathValue("id")
+
+	query, args, err := QB.Select(strings.Join(cartItemColumns, ", ")).
+		From("cart_items").
+		Where("cart_id = ? AND id = ?", cartID, id).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&cartItem, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, cartItem)
+}
+
+// CreateCartItemHandler handles POST requests to add an item to the cart in the path
+func CreateCartItemHandler(w http.ResponseWriter, r *http.Request) {
+	var cartItem models.CartItem
+	if r.FormValue("item_id") == "" || r.FormValue("quantity") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Item ID and Quantity are required")
+		return
+	}
+
+	cartID, err := uuid.Parse(r.PathValue("cart_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid cart ID format")
+		return
+	}
+
+	itemID, err := uuid.Parse(r.FormValue("item_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid item ID format")
+		return
+	}
+
+	optionIDs, err := optionIDsFromForm(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quantity, err := strconv.Atoi(r.FormValue("quantity"))
+	if err != nil || quantity < 1 {
+		utils.HandelError(w, http.StatusBadRequest, "Quantity must be a whole number of at least 1")
+		return
+	}
+
+	cartItem.ID = uuid.New()
+	cartItem.CartID = cartID
+	cartItem.ItemID = itemID
+	cartItem.Quantity = quantity
+	cartItem.OptionIDs = optionIDs
+
+	replace := false
+	if r.FormValue("replace") != "" {
+		replace, err = strconv.ParseBool(r.FormValue("replace"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid replace format")
+			return
+		}
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	if err := assignCartVendor(tx, cartID, itemID, replace); err != nil {
+		if errors.Is(err, errCartVendorMismatch) {
+			utils.HandelError(w, http.StatusConflict, "Cart already contains items from another vendor, send replace=true to empty it and start a new cart")
+			return
+		}
+		if errors.Is(err, errCartCurrencyMismatch) {
+			utils.HandelError(w, http.StatusConflict, "Cart already contains items priced in another currency, send replace=true to empty it and start a new cart")
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Cart or item not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking cart vendor: "+err.Error())
+		return
+	}
+
+	var selectionErr optionSelectionError
+	if _, err := resolveOptions(tx, itemID, optionIDs); err != nil {
+		if errors.As(err, &selectionErr) {
+			utils.HandelError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking item options: "+err.Error())
+		return
+	}
+	if !checkCartItemStock(w, tx, cartID, itemID, uuid.Nil, cartItem.Quantity) {
+		return
+	}
+
+	// Adding the same item with the same options again increases the existing line
+	query, args, err := QB.Insert("cart_items").
+		Columns("id", "cart_id", "item_id", "quantity", "option_ids").
+		Values(cartItem.ID, cartItem.CartID, cartItem.ItemID, cartItem.Quantity, cartItem.OptionIDs).
+		Suffix("ON CONFLICT (cart_id, item_id, option_ids) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity").
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartItemColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.QueryRowx(query, args...).StructScan(&cartItem); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := recalculateCart(tx, cartItem.CartID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, cartItem)
+}
+
+// UpdateCartItemHandler handles PUT requests to update an existing cart item
+func UpdateCartItemHandler(w http.ResponseWriter, r *http.Request) {
+	var cartItem models.CartItem
+	cartID := r.PathValue("cart_id")
+	id := r.PathValue("id")
+
+	query, args, err := QB.Select(strings.Join(cartItemColumns, ", ")).
+		From("cart_items").
+		Where("cart_id = ? AND id = ?", cartID, id).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&cartItem, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Update quantity if provided
+	if r.FormValue("quantity") != "" {
+		quantity, err := strconv.Atoi(r.FormValue("quantity"))
+		if err != nil || quantity < 1 {
+			utils.HandelError(w, http.StatusBadRequest, "Quantity must be a whole number of at least 1")
+			return
+		}
+		cartItem.Quantity = quantity
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkCartItemStock(w, tx, cartItem.CartID, cartItem.ItemID, cartItem.ID, cartItem.Quantity) {
+		return
+	}
+
+	query, args, err = QB.Update("cart_items").
+		Set("quantity", cartItem.Quantity).
+		Where("cart_id = ? AND id = ?", cartItem.CartID, cartItem.ID).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(cartItemColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+
+	if err := tx.QueryRowx(query, args...).StructScan(&cartItem); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart item: "+err.Error())
+		return
+	}
+	if err := recalculateCart(tx, cartItem.CartID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, cartItem)
+}
+
+// DeleteCartItemHandler handles DELETE requests to remove a cart item
+func DeleteCartItemHandler(w http.ResponseWriter, r *http.Request) {
+	cartID, err := uuid.Parse(r.PathValue("cart_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid cart ID format")
+		return
+	}
+	id := r.PathValue("id")
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	query, args, err := QB.Delete("cart_items").
+		Where("cart_id = ? AND id = ?", cartID, id).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building delete query: "+err.Error())
+		return
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting cart item: "+err.Error())
+		return
+	}
+	if err := recalculateCart(tx, cartID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, "Cart item deleted")
+}
@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+var categoryColumns = []string{
+	"id",
+	"vendor_id",
+	"parent_id",
+	"name",
+	"position",
+	"created_at",
+	"updated_at",
+}
+
+var categoryListSpec = listSpec{
+	Sortable:    map[string]string{"position": "position", "name": "name", "created_at": "created_at"},
+	Filterable:  map[string]string{"vendor_id": "vendor_id", "parent_id": "parent_id"},
+	DefaultSort: "position",
+	TieBreaker:  []string{"name", "id"},
+}
+
+// IndexCategoryHandler handles GET requests to fetch categories
+func IndexCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var categories []models.Category
+	meta, ok := paginate(w, r, QB.Select(strings.Join(categoryColumns, ", ")).From("categories"), categoryListSpec, &categories)
+	if !ok {
+		return
+	}
+	sendList(w, meta, categories)
+}
+
+// ShowCategoryHandler handles GET requests to fetch a single category by ID
+func ShowCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category, err := findCategory(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Category not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, category)
+}
+
+// CreateCategoryHandler handles POST requests to create a new category
+func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var category models.Category
+	if r.FormValue("name") == "" || r.FormValue("vendor_id") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Name and vendor_id are required")
+		return
+	}
+
+	vendorID, err := uuid.Parse(r.FormValue("vendor_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+
+	category.ID = uuid.New()
+	category.VendorID = vendorID
+	category.Name = r.FormValue("name")
+	if r.FormValue("position") != "" {
+		category.Position, err = strconv.Atoi(r.FormValue("position"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid position format")
+			return
+		}
+	}
+	if r.FormValue("parent_id") != "" {
+		parentID, err := uuid.Parse(r.FormValue("parent_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid parent_id format")
+			return
+		}
+		category.ParentID = &parentID
+	}
+	if !validateCategoryParent(w, category) {
+		return
+	}
+
+	query, args, err := QB.Insert("categories").
+		Columns("id", "vendor_id", "parent_id", "name", "position").
+		Values(category.ID, category.VendorID, category.ParentID, category.Name, category.Position).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(categoryColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&category); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating category: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, category)
+}
+
+// UpdateCategoryHandler handles PUT requests to update an existing category
+func UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category, err := findCategory(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Category not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !authorizeVendor(w, r, category.VendorID) {
+		return
+	}
+
+	if r.FormValue("name") != "" {
+		category.Name = r.FormValue("name")
+	}
+	if r.FormValue("position") != "" {
+		category.Position, err = strconv.Atoi(r.FormValue("position"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid position format")
+			return
+		}
+	}
+	if r.FormValue("parent_id") != "" {
+		parentID, err := uuid.Parse(r.FormValue("parent_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid parent_id format")
+			return
+		}
+		category.ParentID = &parentID
+	} else if _, ok := r.Form["parent_id"]; ok {
+		// An empty parent_id moves the category back to the top level
+		category.ParentID = nil
+	}
+	if !validateCategoryParent(w, category) {
+		return
+	}
+
+	query, args, err := QB.Update("categories").
+		Set("name", category.Name).
+		Set("position", category.Position).
+		Set("parent_id", category.ParentID).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": category.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(categoryColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&category); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating category: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, category)
+}
+
+// DeleteCategoryHandler handles DELETE requests to remove a category.
+// Subcategories are removed with it and its items become uncategorized.
+func DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, QB.Select("vendor_id").From("categories").Where("id = ?", id)) {
+		return
+	}
+
+	query, args, err := QB.Delete("categories").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting category: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, "Category deleted")
+}
+
+// VendorMenuHandler handles GET requests for a vendor's menu: its categories in display order,
+// each with its subcategories and items, followed by the items without a category
+func VendorMenuHandler(w http.ResponseWriter, r *http.Request) {
+	var vendor models.Vendor
+	query, args, err := QB.Select(strings.Join(vendor_columns, ", ")).From("vendors").Where("id = ?", r.PathValue("id")).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := db.Get(&vendor, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Vendor not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var categories []models.Category
+	query, args, err = QB.Select(strings.Join(categoryColumns, ", ")).
+		From("categories").
+		Where(squirrel.Eq{"vendor_id": vendor.ID}).
+		OrderBy("position", "name").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := db.Select(&categories, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var items []models.Item
+	query, args, err = QB.Select(strings.Join(item_columns, ", ")).
+		From("items").
+		Where(squirrel.Eq{"vendor_id": vendor.ID}).
+		OrderBy("name").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := db.Select(&items, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !setDisplayPrices(w, r, itemRefs(items)) {
+		return
+	}
+
+	menu := buildMenu(vendor, categories, items)
+	models.ApplyCurrency(&menu)
+	utils.SendJSONResponse(w, http.StatusOK, menu)
+}
+
+// buildMenu nests subcategories under their parents and places each item in its category,
+// keeping the order of the given slices
+func buildMenu(vendor models.Vendor, categories []models.Category, items []models.Item) models.Menu {
+	menu := models.Menu{Vendor: vendor, Categories: []models.Category{}, Uncategorized: []models.Item{}}
+
+	itemsByCategory := map[uuid.UUID][]models.Item{}
+	for _, item := range items {
+		if item.CategoryID == nil {
+			menu.Uncategorized = append(menu.Uncategorized, item)
+			continue
+		}
+		itemsByCategory[*item.CategoryID] = append(itemsByCategory[*item.CategoryID], item)
+	}
+
+	children := map[uuid.UUID][]models.Category{}
+	for _, category := range categories {
+		if category.ParentID != nil {
+			category.Items = itemsByCategory[category.ID]
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			category.Items = itemsByCategory[category.ID]
+			category.Subcategories = children[category.ID]
+			menu.Categories = append(menu.Categories, category)
+		}
+	}
+	return menu
+}
+
+func findCategory(id string) (models.Category, error) {
+	var category models.Category
+	query, args, err := QB.Select(strings.Join(categoryColumns, ", ")).From("categories").Where("id = ?", id).ToSql()
+	if err != nil {
+		return category, err
+	}
+	err = db.Get(&category, query, args...)
+	return category, err
+}
+
+// validateCategoryParent keeps categories one level deep: the parent must be a top-level category
+// of the same vendor, and a category that has subcategories cannot become a subcategory itself
+func validateCategoryParent(w http.ResponseWriter, category models.Category) bool {
+	if category.ParentID == nil {
+		return true
+	}
+	if *category.ParentID == category.ID {
+		utils.HandelError(w, http.StatusBadRequest, "A category cannot be its own parent")
+		return false
+	}
+
+	parent, err := findCategory(category.ParentID.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusBadRequest, "Parent category not found")
+			return false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if parent.VendorID != category.VendorID {
+		utils.HandelError(w, http.StatusBadRequest, "Parent category belongs to another vendor")
+		return false
+	}
+	if parent.ParentID != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Categories can only be nested one level deep")
+		return false
+	}
+
+	var children int
+	query, args, err := QB.Select("COUNT(*)").From("categories").Where(squirrel.Eq{"parent_id": category.ID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if err := db.Get(&children, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if children > 0 {
+		utils.HandelError(w, http.StatusBadRequest, "A category with subcategories cannot be nested")
+		return false
+	}
+	return true
+}
+
+// validateItemCategory checks that an item's category exists and belongs to the item's vendor
+func validateItemCategory(w http.ResponseWriter, item models.Item) bool {
+	if item.CategoryID == nil {
+		return true
+	}
+	category, err := findCategory(item.CategoryID.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusBadRequest, "Category not found")
+			return false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if category.VendorID != item.VendorID {
+		utils.HandelError(w, http.StatusBadRequest, "Category belongs to another vendor")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// cartLine is a cart_items row joined with the item it refers to
+type cartLine struct {
+	ItemID    uuid.UUID      `db:"item_id"`
+	Quantity  int            `db:"quantity"`
+	Price     models.Money   `db:"price"`
+	Currency  string         `db:"currency"`
+	VendorID  uuid.UUID      `db:"vendor_id"`
+	OptionIDs pq.StringArray `db:"option_ids"`
+	Options   []pickedOption `db:"-"`
+}
+
+// CheckoutCartHandler handles POST requests that turn a cart into an order in one transaction
+func CheckoutCartHandler(w http.ResponseWriter, r *http.Request) {
+	cartID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid cart id format")
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the cart so concurrent checkouts or cart edits wait for this one
+	var cart models.Cart
+	query, args, err := QB.Select(strings.Join(cartColumns, ", ")).
+		From("carts").
+		Where(squirrel.Eq{"id": cartID}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Get(&cart, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Cart not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error fetching cart: "+err.Error())
+		return
+	}
+
+	var lines []cartLine
+	query, args, err = QB.Select("cart_items.item_id", "cart_items.quantity", "items.price", "items.currency", "items.vendor_id", "cart_items.option_ids").
+		From("cart_items").
+		Join("items ON items.id = cart_items.item_id").
+		Where(squirrel.Eq{"cart_items.cart_id": cartID}).
+		OrderBy("items.name").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Select(&lines, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error fetching cart items: "+err.Error())
+		return
+	}
+	if len(lines) == 0 {
+		utils.HandelError(w, http.StatusBadRequest, "Cart is empty")
+		return
+	}
+
+	vendorID := lines[0].VendorID
+	currency := lines[0].Currency
+	total := models.Money{Currency: currency}
+	for i, line := range lines {
+		if line.VendorID != vendorID {
+			utils.HandelError(w, http.StatusConflict, "Cart contains items from more than one vendor")
+			return
+		}
+		// Amounts in different currencies cannot be added up into one order total
+		if line.Currency != currency || (cart.Currency != nil && *cart.Currency != currency) {
+			utils.HandelError(w, http.StatusConflict, "Cart contains items priced in more than one currency")
+			return
+		}
+		lines[i].Price.Currency = currency
+		if line.Quantity <= 0 {
+			utils.HandelError(w, http.StatusBadRequest, "Cart contains an item with an invalid quantity")
+			return
+		}
+		// The menu may have changed since the line was added, so the options are checked again
+		options, err := resolveOptions(tx, line.ItemID, line.OptionIDs)
+		if err != nil {
+			var selectionErr optionSelectionError
+			if errors.As(err, &selectionErr) {
+				utils.HandelError(w, http.StatusConflict, "Cart item options are no longer valid: "+err.Error())
+				return
+			}
+			utils.HandelError(w, http.StatusInternalServerError, "Error checking item options: "+err.Error())
+			return
+		}
+		for _, option := range options {
+			lines[i].Price = lines[i].Price.Add(option.PriceDelta)
+		}
+		lines[i].Options = options
+		total = total.Add(lines[i].Price.Mul(line.Quantity))
+	}
+
+	if rejectClosedVendor(w, tx, vendorID) {
+		return
+	}
+
+	orderType, tableID, ok := orderPlacement(w, r, tx, vendorID, cart.ID)
+	if !ok {
+		return
+	}
+
+	// Take the ordered quantities out of stock; an item sold out in the meantime fails the checkout
+	quantities := map[uuid.UUID]int{}
+	for _, line := range lines {
+		quantities[line.ItemID] += line.Quantity
+	}
+	if err := reserveStock(tx, quantities); err != nil {
+		var unavailable itemUnavailableError
+		if errors.As(err, &unavailable) {
+			utils.HandelError(w, http.StatusConflict, err.Error())
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+		return
+	}
+
+	order := models.Order{
+		ID:             uuid.New(),
+		TotalOrderCost: total,
+		Currency:       currency,
+		CustomerID:     cart.ID, // carts.id is the owning user's id
+		VendorID:       vendorID,
+		Status:         models.Pending,
+		OrderType:      orderType,
+		TableID:        tableID,
+		Prepaid:        orderType != models.DineIn,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	query, args, err = QB.Insert("orders").
+		Columns("id", "total_order_cost", "currency", "customer_id", "vendor_id", "status", "order_type", "table_id", "prepaid", "created_at", "updated_at").
+		Values(order.ID, order.TotalOrderCost, order.Currency, order.CustomerID, order.VendorID, order.Status, order.OrderType, order.TableID, order.Prepaid, order.CreatedAt, order.UpdatedAt).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.QueryRowx(query, args...).StructScan(&order); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating order: "+err.Error())
+		return
+	}
+
+	// Snapshot the current item prices, including option deltas, and the chosen options onto the order lines
+	insert := QB.Insert("order_items").Columns("id", "order_id", "item_id", "quantity", "price")
+	optionInsert := QB.Insert("order_item_options").Columns("order_item_id", "option_id", "group_name", "name", "price_delta")
+	hasOptions := false
+	for _, line := range lines {
+		orderItemID := uuid.New()
+		insert = insert.Values(orderItemID, order.ID, line.ItemID, line.Quantity, line.Price)
+		for _, option := range line.Options {
+			optionInsert = optionInsert.Values(orderItemID, option.ID, option.GroupName, option.Name, option.PriceDelta)
+			hasOptions = true
+		}
+	}
+	query, args, err = insert.Suffix(fmt.Sprintf("RETURNING %s", strings.Join(orderItemColumns, ", "))).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Select(&order.Items, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating order items: "+err.Error())
+		return
+	}
+	if hasOptions {
+		query, args, err = optionInsert.ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error creating order item options: "+err.Error())
+			return
+		}
+		if err := loadOrderItemOptions(tx, order.Items); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error loading order item options: "+err.Error())
+			return
+		}
+	}
+
+	// Empty the cart
+	query, args, err = QB.Delete("cart_items").Where(squirrel.Eq{"cart_id": cartID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error emptying cart: "+err.Error())
+		return
+	}
+	query, args, err = QB.Update("carts").
+		Set("total_price", 0).
+		Set("quantity", 0).
+		Set("vendor_id", nil).
+		Set("currency", nil).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": cartID}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error resetting cart: "+err.Error())
+		return
+	}
+	if err := notifyOrder(tx, orderCreatedEvent, order); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error publishing order event: "+err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&order)
+	utils.SendJSONResponse(w, http.StatusCreated, order)
+}
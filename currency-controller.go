@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var exchangeRateColumns = []string{"base_currency", "quote_currency", "rate", "updated_at"}
+
+// vendorCurrency returns the currency a vendor's items are priced in
+func vendorCurrency(q sqlx.Queryer, vendorID uuid.UUID) (string, error) {
+	var currency string
+	query, args, err := QB.Select("currency").From("vendors").Where(squirrel.Eq{"id": vendorID}).ToSql()
+	if err != nil {
+		return "", err
+	}
+	return currency, sqlx.Get(q, &currency, query, args...)
+}
+
+// vendorHasItems reports whether any item is priced in the vendor's currency
+func vendorHasItems(q sqlx.Queryer, vendorID uuid.UUID) (bool, error) {
+	query, args, err := QB.Select("1").
+		From("items").
+		Where(squirrel.Eq{"vendor_id": vendorID}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	return exists, sqlx.Get(q, &exists, query, args...)
+}
+
+// displayCurrency is the currency approximate prices are shown in: the display_currency
+// parameter, or else the signed-in user's preferred currency. It is empty when neither is set.
+func displayCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if value := r.URL.Query().Get("display_currency"); value != "" {
+		currency, ok := models.ParseCurrency(value)
+		if !ok {
+			utils.HandelError(w, http.StatusBadRequest, "Unsupported currency "+value)
+			return "", false
+		}
+		return currency, true
+	}
+	if user, ok := CurrentUser(r); ok && user.PreferredCurrency != nil {
+		return *user.PreferredCurrency, true
+	}
+	return "", true
+}
+
+// ratesInto loads the rates converting other currencies into currency. A rate stored the
+// other way round is inverted.
+func ratesInto(currency string) (map[string]*big.Rat, error) {
+	var stored []models.ExchangeRate
+	query, args, err := QB.Select(exchangeRateColumns...).
+		From("exchange_rates").
+		Where(squirrel.Or{squirrel.Eq{"quote_currency": currency}, squirrel.Eq{"base_currency": currency}}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Select(&stored, query, args...); err != nil {
+		return nil, err
+	}
+	rates := map[string]*big.Rat{}
+	for _, rate := range stored {
+		value, ok := new(big.Rat).SetString(rate.Rate.String())
+		if !ok || value.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %s for %s/%s", rate.Rate, rate.Base, rate.Quote)
+		}
+		if rate.Quote == currency {
+			rates[rate.Base] = value
+		} else if _, direct := rates[rate.Quote]; !direct {
+			rates[rate.Quote] = value.Inv(value)
+		}
+	}
+	return rates, nil
+}
+
+// itemRefs points at each item so their display prices can be set in place
+func itemRefs(items []models.Item) []*models.Item {
+	refs := make([]*models.Item, len(items))
+	for i := range items {
+		refs[i] = &items[i]
+	}
+	return refs
+}
+
+// setDisplayPrices fills in the approximate price of items sold in another currency than the
+// one the user wants to see. Items are left alone when no rate is known.
+func setDisplayPrices(w http.ResponseWriter, r *http.Request, items []*models.Item) bool {
+	currency, ok := displayCurrency(w, r)
+	if !ok {
+		return false
+	}
+	if currency == "" || len(items) == 0 {
+		return true
+	}
+	rates, err := ratesInto(currency)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error loading exchange rates: "+err.Error())
+		return false
+	}
+	for _, item := range items {
+		rate, ok := rates[item.Currency]
+		if item.Currency == currency || !ok {
+			continue
+		}
+		price := item.Price
+		price.Currency = item.Currency
+		converted := price.Convert(currency, rate)
+		item.DisplayPrice = &converted
+	}
+	return true
+}
+
+// parseCurrencyPair reads the {base} and {quote} path values of an exchange rate
+func parseCurrencyPair(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	base, baseOK := models.ParseCurrency(r.PathValue("base"))
+	quote, quoteOK := models.ParseCurrency(r.PathValue("quote"))
+	if !baseOK || !quoteOK {
+		utils.HandelError(w, http.StatusBadRequest, "Unsupported currency pair")
+		return "", "", false
+	}
+	if base == quote {
+		utils.HandelError(w, http.StatusBadRequest, "Base and quote currency must differ")
+		return "", "", false
+	}
+	return base, quote, true
+}
+
+// IndexExchangeRatesHandler handles GET requests for the exchange rates used to show approximate prices
+func IndexExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates := []models.ExchangeRate{}
+	query, args, err := QB.Select(exchangeRateColumns...).
+		From("exchange_rates").
+		OrderBy("base_currency", "quote_currency").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Select(&rates, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, rates)
+}
+
+// PutExchangeRateHandler handles PUT requests setting how many units of the quote currency
+// one unit of the base currency is shown as
+//
+//	PUT /exchange-rates/USD/EUR  rate=0.92
+func PutExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+	base, quote, ok := parseCurrencyPair(w, r)
+	if !ok {
+		return
+	}
+	value := strings.TrimSpace(r.FormValue("rate"))
+	rate, valid := new(big.Rat).SetString(value)
+	if !valid || strings.Trim(value, "0123456789.") != "" || rate.Sign() <= 0 {
+		utils.HandelError(w, http.StatusBadRequest, "rate must be a positive decimal number")
+		return
+	}
+
+	var exchangeRate models.ExchangeRate
+	query, args, err := QB.Insert("exchange_rates").
+		Columns("base_currency", "quote_currency", "rate", "updated_at").
+		Values(base, quote, value, time.Now()).
+		Suffix(fmt.Sprintf("ON CONFLICT (base_currency, quote_currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at RETURNING %s", strings.Join(exchangeRateColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&exchangeRate); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error saving exchange rate: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, exchangeRate)
+}
+
+// DeleteExchangeRateHandler handles DELETE requests removing an exchange rate
+func DeleteExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+	base, quote, ok := parseCurrencyPair(w, r)
+	if !ok {
+		return
+	}
+	query, args, err := QB.Delete("exchange_rates").
+		Where(squirrel.Eq{"base_currency": base, "quote_currency": quote}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		utils.HandelError(w, http.StatusNotFound, "Exchange rate not found")
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, "Exchange rate deleted")
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the currency of vendors that have not chosen one
+const DefaultCurrency = "USD"
+
+// currencyExponents lists the ISO 4217 currencies money can be kept in with the number of
+// decimal places each is quoted to. Funds codes and currencies quoted to more than MoneyScale
+// places, such as CLF, are left out.
+var currencyExponents = map[string]int{
+	// No minor unit
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	// Thousandths
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	// Hundredths
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2, "AWG": 2,
+	"AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BMD": 2, "BND": 2, "BOB": 2, "BRL": 2,
+	"BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CNY": 2,
+	"COP": 2, "CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2,
+	"ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2,
+	"GMD": 2, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "IRR": 2, "JMD": 2, "KES": 2, "KGS": 2, "KHR": 2, "KPW": 2, "KYD": 2, "KZT": 2,
+	"LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2,
+	"MMK": 2, "MNT": 2, "MOP": 2, "MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2,
+	"MZN": 2, "NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "SAR": 2,
+	"SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2,
+	"SSP": 2, "STN": 2, "SVC": 2, "SYP": 2, "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TOP": 2,
+	"TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "USD": 2, "UYU": 2, "UZS": 2, "VES": 2,
+	"WST": 2, "XCD": 2, "XCG": 2, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2,
+}
+
+// ParseCurrency upper-cases an ISO 4217 code and reports whether it is supported
+func ParseCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	_, ok := currencyExponents[code]
+	return code, ok
+}
+
+// CurrencyExponent returns the number of decimal places the currency is quoted to
+func CurrencyExponent(code string) (int, bool) {
+	exponent, ok := currencyExponents[code]
+	return exponent, ok
+}
+
+// ExchangeRate converts prices for display: one unit of Base is worth Rate units of Quote
+type ExchangeRate struct {
+	Base      string      `db:"base_currency" json:"base_currency"`
+	Quote     string      `db:"quote_currency" json:"quote_currency"`
+	Rate      json.Number `db:"rate" json:"rate"`
+	UpdatedAt time.Time   `db:"updated_at" json:"updated_at"`
+}
+
+var moneyType = reflect.TypeOf(Money{})
+
+// ApplyCurrency gives every Money in v the currency of the struct holding it, so it is written
+// to JSON at that currency's precision. Nested values without a currency of their own, such as
+// an order's items, take their parent's. v must be a pointer, or a slice, for the amounts to be set.
+func ApplyCurrency(v interface{}) {
+	applyCurrency(reflect.ValueOf(v), "")
+}
+
+func applyCurrency(v reflect.Value, currency string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			applyCurrency(v.Elem(), currency)
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			applyCurrency(v.Index(i), currency)
+		}
+	case reflect.Struct:
+		if v.Type() == moneyType {
+			if v.CanSet() && currency != "" && v.FieldByName("Currency").String() == "" {
+				v.FieldByName("Currency").SetString(currency)
+			}
+			return
+		}
+		if own := v.FieldByName("Currency"); own.IsValid() {
+			if own.Kind() == reflect.Pointer && !own.IsNil() {
+				own = own.Elem()
+			}
+			if own.Kind() == reflect.String && own.String() != "" {
+				currency = own.String()
+			}
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				applyCurrency(v.Field(i), currency)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"intership/utils"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Changes are fanned out through Postgres LISTEN/NOTIFY rather than in memory, so a client
+// streaming from one server instance hears about changes made through any other.
+
+const (
+	orderEventsChannel = "order_events"
+	tableEventsChannel = "table_events"
+	// A stream that falls this many events behind is closed; the client reconnects and reloads
+	eventBufferSize  = 64
+	sseHeartbeat     = 25 * time.Second
+	sseRetry         = 3 * time.Second
+	listenerPingTime = 90 * time.Second
+)
+
+// resyncEventType tells clients notifications may have been lost and they should reload
+const resyncEventType = "resync"
+
+// streamEvent is the payload sent through NOTIFY. VendorID and CustomerID decide which streams receive it.
+type streamEvent struct {
+	Type       string          `json:"type"`
+	VendorID   uuid.UUID       `json:"vendor_id"`
+	CustomerID uuid.UUID       `json:"customer_id"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+type subscriber struct {
+	channel string
+	filter  func(streamEvent) bool
+	events  chan streamEvent
+}
+
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+var hub = &eventHub{subscribers: map[*subscriber]struct{}{}}
+
+func (h *eventHub) subscribe(channel string, filter func(streamEvent) bool) *subscriber {
+	sub := &subscriber{channel: channel, filter: filter, events: make(chan streamEvent, eventBufferSize)}
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// dispatch hands an event to the matching subscribers without ever blocking on a slow one
+func (h *eventHub) dispatch(channel string, event streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		if event.Type != resyncEventType && (sub.channel != channel || !sub.filter(event)) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// ListenForEvents opens the LISTEN connection that feeds the event streams. It is called once at startup.
+func ListenForEvents(databaseURL string) error {
+	listener := pq.NewListener(databaseURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event listener: %v", err)
+		}
+	})
+	for _, channel := range []string{orderEventsChannel, tableEventsChannel} {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return fmt.Errorf("listening on %s: %w", channel, err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case notification := <-listener.Notify:
+				if notification == nil {
+					// The connection was re-established; anything sent meanwhile is gone
+					hub.dispatch("", streamEvent{Type: resyncEventType})
+					continue
+				}
+				var event streamEvent
+				if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+					log.Printf("event listener: bad payload on %s: %v", notification.Channel, err)
+					continue
+				}
+				hub.dispatch(notification.Channel, event)
+			case <-time.After(listenerPingTime):
+				go listener.Ping()
+			}
+		}
+	}()
+	return nil
+}
+
+// publishEvent sends an event to every listening server instance. Inside a transaction
+// Postgres holds the notification back until commit, so rolled back changes are never announced.
+func publishEvent(exec sqlx.Execer, channel string, eventType string, vendorID, customerID uuid.UUID, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(streamEvent{Type: eventType, VendorID: vendorID, CustomerID: customerID, Data: encoded})
+	if err != nil {
+		return err
+	}
+	_, err = exec.Exec("SELECT pg_notify($1, $2)", channel, string(payload))
+	return err
+}
+
+// serveEventStream writes the events accepted by filter as Server-Sent Events until the client goes away
+func serveEventStream(w http.ResponseWriter, r *http.Request, channel string, filter func(streamEvent) bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.HandelError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	sub := hub.subscribe(channel, filter)
+	defer hub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // keep proxies from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			data := event.Data
+			if len(data) == 0 {
+				data = json.RawMessage("{}")
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
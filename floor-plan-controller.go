@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTableCapacity = 2
+	defaultTableZone     = "indoor"
+	maxTableZoneLength   = 100
+)
+
+// tableLayoutFromForm applies the capacity, zone and position fields to the table when they are given
+func tableLayoutFromForm(w http.ResponseWriter, r *http.Request, table *models.Table) bool {
+	if !formInt(w, r, "capacity", &table.Capacity) || !formInt(w, r, "position_x", &table.PositionX) || !formInt(w, r, "position_y", &table.PositionY) {
+		return false
+	}
+	if table.Capacity < 1 {
+		utils.HandelError(w, http.StatusBadRequest, "capacity must be at least 1")
+		return false
+	}
+	if table.PositionX < 0 || table.PositionY < 0 {
+		utils.HandelError(w, http.StatusBadRequest, "position_x and position_y cannot be negative")
+		return false
+	}
+	if zone := strings.ToLower(strings.TrimSpace(r.FormValue("zone"))); zone != "" {
+		if len(zone) > maxTableZoneLength {
+			utils.HandelError(w, http.StatusBadRequest, "zone is too long")
+			return false
+		}
+		table.Zone = zone
+	}
+	return true
+}
+
+// isTableOccupied reports whether someone is sitting at the table or it has been taken out of service
+func isTableOccupied(table models.Table) bool {
+	return !table.IsAvailable || table.CustomerID != nil
+}
+
+// VendorFloorPlanHandler handles GET requests for a vendor's tables grouped by zone with live occupancy.
+// Seated customers are only shown to the vendor's staff.
+func VendorFloorPlanHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	manages, err := canManageVendor(r, vendorID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor access: "+err.Error())
+		return
+	}
+
+	var tables []models.Table
+	query, args, err := QB.Select(strings.Join(table_columns, ", ")).
+		From("tables").
+		Where(squirrel.Eq{"vendor_id": vendorID}).
+		OrderBy("zone", "position_y", "position_x", "name").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Select(&tables, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	plan := models.FloorPlan{VendorID: vendorID, Zones: []models.FloorZone{}}
+	for _, table := range tables {
+		if len(plan.Zones) == 0 || plan.Zones[len(plan.Zones)-1].Name != table.Zone {
+			plan.Zones = append(plan.Zones, models.FloorZone{Name: table.Zone, Tables: []models.FloorTable{}})
+		}
+		zone := &plan.Zones[len(plan.Zones)-1]
+		occupied := isTableOccupied(table)
+		if !manages {
+			table.CustomerID = nil
+		}
+		zone.Tables = append(zone.Tables, models.FloorTable{Table: table, Occupied: occupied})
+		zone.Seats += table.Capacity
+		if occupied {
+			zone.OccupiedTables++
+		} else {
+			zone.FreeSeats += table.Capacity
+		}
+	}
+	utils.SendJSONResponse(w, http.StatusOK, plan)
+}
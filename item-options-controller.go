@@ -0,0 +1,548 @@
+package controllers
+
+import (
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var optionGroupColumns = []string{
+	"id",
+	"item_id",
+	"name",
+	"min_select",
+	"max_select",
+	"position",
+	"created_at",
+	"updated_at",
+}
+
+var optionColumns = []string{
+	"id",
+	"option_group_id",
+	"name",
+	"price_delta",
+	"(SELECT items.currency FROM option_groups JOIN items ON items.id = option_groups.item_id WHERE option_groups.id = options.option_group_id) AS currency",
+	"position",
+	"created_at",
+	"updated_at",
+}
+
+// optionSelectionError is a customer's option choice that breaks an item's option rules
+type optionSelectionError string
+
+func (e optionSelectionError) Error() string { return string(e) }
+
+// pickedOption is a chosen option together with the group it belongs to
+type pickedOption struct {
+	ID         uuid.UUID    `db:"id"`
+	GroupID    uuid.UUID    `db:"option_group_id"`
+	GroupName  string       `db:"group_name"`
+	Name       string       `db:"name"`
+	PriceDelta models.Money `db:"price_delta"`
+}
+
+// optionIDsFromForm reads option_ids from a submitted form, either repeated or comma separated,
+// and returns them parsed, deduplicated and sorted so equal selections compare equal
+func optionIDsFromForm(r *http.Request) (pq.StringArray, error) {
+	seen := map[string]bool{}
+	ids := pq.StringArray{}
+	for _, value := range r.Form["option_ids"] {
+		for _, raw := range strings.Split(value, ",") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return nil, optionSelectionError("Invalid option id " + raw)
+			}
+			if !seen[id.String()] {
+				seen[id.String()] = true
+				ids = append(ids, id.String())
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// resolveOptions loads the chosen options of an item and checks them against the min/max rules of every
+// option group of the item. Rule violations are returned as optionSelectionError.
+func resolveOptions(q sqlx.Queryer, itemID uuid.UUID, optionIDs []string) ([]pickedOption, error) {
+	var groups []models.OptionGroup
+	query, args, err := QB.Select(optionGroupColumns...).
+		From("option_groups").
+		Where(squirrel.Eq{"item_id": itemID}).
+		OrderBy("position", "name").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &groups, query, args...); err != nil {
+		return nil, err
+	}
+
+	picked := []pickedOption{}
+	if len(optionIDs) > 0 {
+		query, args, err = QB.Select("options.id", "options.option_group_id", "option_groups.name AS group_name", "options.name", "options.price_delta").
+			From("options").
+			Join("option_groups ON option_groups.id = options.option_group_id").
+			Where(squirrel.Eq{"options.id": optionIDs, "option_groups.item_id": itemID}).
+			OrderBy("option_groups.position", "options.position", "options.name").
+			ToSql()
+		if err != nil {
+			return nil, err
+		}
+		if err := sqlx.Select(q, &picked, query, args...); err != nil {
+			return nil, err
+		}
+		if len(picked) != len(optionIDs) {
+			return nil, optionSelectionError("One or more options do not belong to this item")
+		}
+	}
+
+	chosen := map[uuid.UUID]int{}
+	for _, option := range picked {
+		chosen[option.GroupID]++
+	}
+	for _, group := range groups {
+		count := chosen[group.ID]
+		if count < group.MinSelect || count > group.MaxSelect {
+			if group.MinSelect == group.MaxSelect {
+				return nil, optionSelectionError(fmt.Sprintf("Choose exactly %d option(s) for %s", group.MinSelect, group.Name))
+			}
+			return nil, optionSelectionError(fmt.Sprintf("Choose between %d and %d options for %s", group.MinSelect, group.MaxSelect, group.Name))
+		}
+	}
+	return picked, nil
+}
+
+// loadOptionGroups returns the option groups of an item, each with its options, in display order
+func loadOptionGroups(q sqlx.Queryer, itemID uuid.UUID) ([]models.OptionGroup, error) {
+	groups := []models.OptionGroup{}
+	query, args, err := QB.Select(optionGroupColumns...).
+		From("option_groups").
+		Where(squirrel.Eq{"item_id": itemID}).
+		OrderBy("position", "name").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &groups, query, args...); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	groupIDs := make([]uuid.UUID, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = group.ID
+	}
+	var options []models.Option
+	query, args, err = QB.Select(optionColumns...).
+		From("options").
+		Where(squirrel.Eq{"option_group_id": groupIDs}).
+		OrderBy("position", "name").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &options, query, args...); err != nil {
+		return nil, err
+	}
+	byGroup := map[uuid.UUID][]models.Option{}
+	for _, option := range options {
+		byGroup[option.OptionGroupID] = append(byGroup[option.OptionGroupID], option)
+	}
+	for i := range groups {
+		groups[i].Options = byGroup[groups[i].ID]
+	}
+	return groups, nil
+}
+
+// optionGroupVendorLookup selects the vendor owning the item an option group belongs to
+func optionGroupVendorLookup(id string) squirrel.SelectBuilder {
+	return QB.Select("items.vendor_id").
+		From("option_groups").
+		Join("items ON items.id = option_groups.item_id").
+		Where("option_groups.id = ?", id)
+}
+
+// optionVendorLookup selects the vendor owning the item an option belongs to
+func optionVendorLookup(id string) squirrel.SelectBuilder {
+	return QB.Select("items.vendor_id").
+		From("options").
+		Join("option_groups ON option_groups.id = options.option_group_id").
+		Join("items ON items.id = option_groups.item_id").
+		Where("options.id = ?", id)
+}
+
+// formInt reads an optional integer form value into target, leaving it unchanged when the field is absent
+func formInt(w http.ResponseWriter, r *http.Request, field string, target *int) bool {
+	if r.FormValue(field) == "" {
+		return true
+	}
+	value, err := strconv.Atoi(r.FormValue(field))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s format", field))
+		return false
+	}
+	*target = value
+	return true
+}
+
+// validSelectionRange mirrors the chk_selection constraint on option_groups
+func validSelectionRange(w http.ResponseWriter, group models.OptionGroup) bool {
+	if group.MinSelect < 0 || group.MaxSelect < 1 || group.MinSelect > group.MaxSelect {
+		utils.HandelError(w, http.StatusBadRequest, "min_select must be between 0 and max_select, and max_select at least 1")
+		return false
+	}
+	return true
+}
+
+// CreateOptionGroupHandler handles POST requests to add an option group to an item
+func CreateOptionGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var group models.OptionGroup
+	if r.FormValue("item_id") == "" || r.FormValue("name") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Item ID and name are required")
+		return
+	}
+	itemID, err := uuid.Parse(r.FormValue("item_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid item_id format")
+		return
+	}
+	if !authorizeVendorOf(w, r, QB.Select("vendor_id").From("items").Where(squirrel.Eq{"id": itemID})) {
+		return
+	}
+
+	group.ID = uuid.New()
+	group.ItemID = itemID
+	group.Name = r.FormValue("name")
+	group.MaxSelect = 1
+	if !formInt(w, r, "min_select", &group.MinSelect) {
+		return
+	}
+	if !formInt(w, r, "max_select", &group.MaxSelect) {
+		return
+	}
+	if !formInt(w, r, "position", &group.Position) {
+		return
+	}
+	if !validSelectionRange(w, group) {
+		return
+	}
+
+	query, args, err := QB.Insert("option_groups").
+		Columns("id", "item_id", "name", "min_select", "max_select", "position").
+		Values(group.ID, group.ItemID, group.Name, group.MinSelect, group.MaxSelect, group.Position).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(optionGroupColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&group); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating option group: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, group)
+}
+
+// UpdateOptionGroupHandler handles PUT requests to update an option group
+func UpdateOptionGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var group models.OptionGroup
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, optionGroupVendorLookup(id)) {
+		return
+	}
+
+	query, args, err := QB.Select(optionGroupColumns...).From("option_groups").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := db.Get(&group, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.FormValue("name") != "" {
+		group.Name = r.FormValue("name")
+	}
+	if !formInt(w, r, "min_select", &group.MinSelect) {
+		return
+	}
+	if !formInt(w, r, "max_select", &group.MaxSelect) {
+		return
+	}
+	if !formInt(w, r, "position", &group.Position) {
+		return
+	}
+	if !validSelectionRange(w, group) {
+		return
+	}
+
+	query, args, err = QB.Update("option_groups").
+		Set("name", group.Name).
+		Set("min_select", group.MinSelect).
+		Set("max_select", group.MaxSelect).
+		Set("position", group.Position).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": group.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(optionGroupColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&group); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating option group: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, group)
+}
+
+// DeleteOptionGroupHandler handles DELETE requests to remove an option group and its options
+func DeleteOptionGroupHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, optionGroupVendorLookup(id)) {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	var itemID uuid.UUID
+	query, args, err := QB.Delete("option_groups").Where("id = ?", id).Suffix("RETURNING item_id").ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Get(&itemID, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting option group: "+err.Error())
+		return
+	}
+	// Carts priced with the removed options drop their deltas
+	if err := recalculateCartsWithItem(tx, itemID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, "Option group deleted")
+}
+
+// CreateOptionHandler handles POST requests to add an option to an option group
+func CreateOptionHandler(w http.ResponseWriter, r *http.Request) {
+	var option models.Option
+	if r.FormValue("option_group_id") == "" || r.FormValue("name") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Option group ID and name are required")
+		return
+	}
+	groupID, err := uuid.Parse(r.FormValue("option_group_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid option_group_id format")
+		return
+	}
+	if !authorizeVendorOf(w, r, optionGroupVendorLookup(groupID.String())) {
+		return
+	}
+	// Price deltas are in the currency of the item
+	query, args, err := QB.Select("items.currency").
+		From("option_groups").
+		Join("items ON items.id = option_groups.item_id").
+		Where(squirrel.Eq{"option_groups.id": groupID}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Get(&option.Currency, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error loading item currency: "+err.Error())
+		return
+	}
+
+	option.ID = uuid.New()
+	option.OptionGroupID = groupID
+	option.Name = r.FormValue("name")
+	if r.FormValue("price_delta") != "" {
+		option.PriceDelta, err = models.ParseMoney(r.FormValue("price_delta"), option.Currency)
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid price_delta: "+err.Error())
+			return
+		}
+	}
+	if !formInt(w, r, "position", &option.Position) {
+		return
+	}
+
+	query, args, err = QB.Insert("options").
+		Columns("id", "option_group_id", "name", "price_delta", "position").
+		Values(option.ID, option.OptionGroupID, option.Name, option.PriceDelta, option.Position).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(optionColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&option); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating option: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&option)
+	utils.SendJSONResponse(w, http.StatusCreated, option)
+}
+
+// UpdateOptionHandler handles PUT requests to update an option; carts holding it are repriced
+func UpdateOptionHandler(w http.ResponseWriter, r *http.Request) {
+	var option models.Option
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, optionVendorLookup(id)) {
+		return
+	}
+
+	query, args, err := QB.Select(optionColumns...).From("options").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := db.Get(&option, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.FormValue("name") != "" {
+		option.Name = r.FormValue("name")
+	}
+	if r.FormValue("price_delta") != "" {
+		option.PriceDelta, err = models.ParseMoney(r.FormValue("price_delta"), option.Currency)
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid price_delta: "+err.Error())
+			return
+		}
+	}
+	if !formInt(w, r, "position", &option.Position) {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	query, args, err = QB.Update("options").
+		Set("name", option.Name).
+		Set("price_delta", option.PriceDelta).
+		Set("position", option.Position).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": option.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(optionColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.QueryRowx(query, args...).StructScan(&option); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating option: "+err.Error())
+		return
+	}
+	if err := recalculateCartsWithOption(tx, option.ID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&option)
+	utils.SendJSONResponse(w, http.StatusOK, option)
+}
+
+// DeleteOptionHandler handles DELETE requests to remove an option; carts holding it are repriced
+func DeleteOptionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid option id format")
+		return
+	}
+	if !authorizeVendorOf(w, r, optionVendorLookup(id.String())) {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	query, args, err := QB.Delete("options").Where(squirrel.Eq{"id": id}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting option: "+err.Error())
+		return
+	}
+	if err := recalculateCartsWithOption(tx, id); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, "Option deleted")
+}
+
+// loadOrderItemOptions attaches the option snapshots to each order line
+func loadOrderItemOptions(q sqlx.Queryer, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	var options []models.OrderItemOption
+	query, args, err := QB.Select("id", "order_item_id", "option_id", "group_name", "name", "price_delta").
+		From("order_item_options").
+		Where(squirrel.Eq{"order_item_id": ids}).
+		OrderBy("group_name", "name").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := sqlx.Select(q, &options, query, args...); err != nil {
+		return err
+	}
+	byItem := map[uuid.UUID][]models.OrderItemOption{}
+	for _, option := range options {
+		byItem[option.OrderItemID] = append(byItem[option.OrderItemID], option)
+	}
+	for i := range items {
+		items[i].Options = byItem[items[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// Full-text matches rank first, trigram similarity on the name catches typos and partial words
+const itemRankSQL = "ts_rank(search_vector, websearch_to_tsquery('simple', ?)) + similarity(name, ?) AS rank"
+
+var itemSearchSpec = listSpec{
+	Sortable:    map[string]string{"relevance": "rank", "price": "price", "name": "name"},
+	DefaultSort: "-relevance",
+	TieBreaker:  []string{"id"},
+}
+
+// SearchItemsHandler handles GET requests to search the menu items of every vendor.
+// Price bounds compare amounts as given, so they are best combined with a currency.
+//
+//	GET /items/search?q=burger&vendor_id=<id>&currency=EUR&min_price=5&max_price=20
+func SearchItemsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	term := strings.TrimSpace(params.Get("q"))
+	if term == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Search query q is required")
+		return
+	}
+
+	selectQuery := QB.Select(item_columns...).
+		Column(squirrel.Expr(itemRankSQL, term, term)).
+		From("items").
+		Where(squirrel.Or{
+			squirrel.Expr("search_vector @@ websearch_to_tsquery('simple', ?)", term),
+			squirrel.Expr("name % ?", term),
+		})
+
+	if value := params.Get("vendor_id"); value != "" {
+		vendorID, err := uuid.Parse(value)
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+			return
+		}
+		selectQuery = selectQuery.Where(squirrel.Eq{"vendor_id": vendorID})
+	}
+	if value := params.Get("currency"); value != "" {
+		currency, ok := models.ParseCurrency(value)
+		if !ok {
+			utils.HandelError(w, http.StatusBadRequest, "Unsupported currency "+value)
+			return
+		}
+		selectQuery = selectQuery.Where(squirrel.Eq{"currency": currency})
+	}
+	if value := params.Get("min_price"); value != "" {
+		minPrice, err := models.ParseMoney(value, "")
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid min_price format")
+			return
+		}
+		selectQuery = selectQuery.Where(squirrel.GtOrEq{"price": minPrice})
+	}
+	if value := params.Get("max_price"); value != "" {
+		maxPrice, err := models.ParseMoney(value, "")
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid max_price format")
+			return
+		}
+		selectQuery = selectQuery.Where(squirrel.LtOrEq{"price": maxPrice})
+	}
+
+	results := []models.ItemSearchResult{}
+	meta, ok := paginate(w, r, selectQuery, itemSearchSpec, &results)
+	if !ok {
+		return
+	}
+	refs := make([]*models.Item, len(results))
+	for i := range results {
+		refs[i] = &results[i].Item
+	}
+	if !setDisplayPrices(w, r, refs) {
+		return
+	}
+	sendList(w, meta, results)
+}
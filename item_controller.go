@@ -1,196 +1,400 @@
-package controllers
-
-import (
-	"fmt"
-	"intership/models"
-	"intership/utils"
-	"net/http"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
-	_ "github.com/jmoiron/sqlx"
-)
-
-var item_columns = []string{
-	"id",
-	"vendor_id",
-	"name",
-	"price",
-	"img",
-	"created_at",
-	"updated_at",
-	fmt.Sprintf("CASE WHEN NULLIF(img, '') IS NOT NULL THEN FORMAT('%s/%%s', img) ELSE NULL END AS img", Domain),
-}
-
-// IndexItemHandler handles GET requests to fetch all items
-func IndexItemHandler(w http.ResponseWriter, r *http.Request) {
-	var items []models.Item
-	query, args, err := QB.Select(strings.Join(item_columns, ", ")).From("items").ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Select(&items, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, items)
-}
-
-// ShowItemHandler handles GET requests to fetch a single item by ID
-func ShowItemHandler(w http.ResponseWriter, r *http.Request) {
-	var item models.Item
-	id := r.PathValue("id")
-	query, args, err := QB.Select(strings.Join(item_columns, ", ")).From("items").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&item, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, item)
-}
-
-// CreateItemHandler handles POST requests to create a new item
-func CreateItemHandler(w http.ResponseWriter, r *http.Request) {
-	var item models.Item
-	if r.FormValue("name") == "" || r.FormValue("price") == "" || r.FormValue("vendor_id") == "" {
-		utils.HandelError(w, http.StatusBadRequest, "Name, price, and vendor_id are required")
-		return
-	}
-
-	price, err := strconv.ParseFloat(r.FormValue("price"), 64)
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid price format")
-		return
-	}
-
-	vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
-		return
-	}
-
-	item.ID = uuid.New() // Generate new UUID
-	item.Name = r.FormValue("name")
-	item.Price = price
-	item.VendorID = vendorID // Set vendor_id from request
-
-	// Handle image upload
-	file, fileHeader, err := r.FormFile("img")
-	if err != nil && err != http.ErrMissingFile {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid file")
-		return
-	} else if err == nil {
-		defer file.Close()
-		imageName, err := utils.SaveImageFile(file, "items", fileHeader.Filename) // Save image in the "items" directory
-		if err != nil {
-			utils.HandelError(w, http.StatusInternalServerError, "Error saving image")
-			return
-		}
-		item.Img = &imageName // Store image path in the item
-	}
-
-	// Build SQL query for inserting item
-	query, args, err := QB.Insert("items").Columns("id", "vendor_id", "name", "price", "img").Values(item.ID, item.VendorID, item.Name, item.Price, item.Img).Suffix(fmt.Sprintf("RETURNING %s", strings.Join(item_columns, ", "))).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
-		return
-	}
-
-	// Execute query and scan the result into the item struct
-	if err := db.QueryRowx(query, args...).StructScan(&item); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error creating item: "+err.Error())
-		return
-	}
-
-	utils.SendJSONResponse(w, http.StatusCreated, item)
-}
-
-// UpdateItemHandler handles PUT requests to update an existing item
-func UpdateItemHandler(w http.ResponseWriter, r *http.Request) {
-	var item models.Item
-	id := r.PathValue("id")
-
-	query, args, err := QB.Select(strings.Join(item_columns, ", ")).From("items").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&item, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	// Update fields if provided
-	if r.FormValue("name") != "" {
-		item.Name = r.FormValue("name")
-	}
-	if r.FormValue("price") != "" {
-		price, err := strconv.ParseFloat(r.FormValue("price"), 64)
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid price format")
-			return
-		}
-		item.Price = price
-	}
-	if r.FormValue("vendor_id") != "" {
-		vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
-			return
-		}
-		item.VendorID = vendorID // Update vendor_id as necessary
-	}
-	if r.FormValue("img") != "" {
-		img := r.FormValue("img") // Update image as necessary
-		item.Img = &img           // Update image path as necessary
-	}
-
-	query, args, err = QB.Update("items").
-		Set("name", item.Name).
-		Set("price", item.Price).
-		Set("vendor_id", item.VendorID). // Ensure vendor_id is updated
-		Set("img", item.Img).
-		Set("updated_at", time.Now()).
-		Where(squirrel.Eq{"id": item.ID}).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(item_columns, ", "))).
-		ToSql()
-
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&item); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error updating item: "+err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, item)
-}
-
-// DeleteItemHandler handles DELETE requests to remove an item
-func DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	query, args, err := QB.Delete("items").Where("id=?", id).Suffix("RETURNING img").ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error deleting item: "+err.Error())
-		return
-	}
-
-	var img *string
-	if err := db.QueryRowx(query, args...).Scan(&img); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error getting image: "+err.Error())
-		return
-	}
-
-	utils.SendJSONResponse(w, http.StatusOK, "Item deleted")
-}
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	_ "github.com/jmoiron/sqlx"
+)
+
+var item_columns = []string{
+	"id",
+	"vendor_id",
+	"category_id",
+	"name",
+	"price",
+	"currency",
+	"img",
+	"is_available",
+	"stock",
+	"sold_out",
+	"created_at",
+	"updated_at",
+	fmt.Sprintf("CASE WHEN NULLIF(img, '') IS NOT NULL THEN FORMAT('%s/%%s', img) ELSE NULL END AS img", Domain),
+}
+
+var itemListSpec = listSpec{
+	Sortable:    map[string]string{"name": "name", "price": "price", "created_at": "created_at"},
+	Filterable:  map[string]string{"vendor_id": "vendor_id", "is_available": "is_available", "currency": "currency"},
+	DefaultSort: "name",
+	TieBreaker:  []string{"id"},
+}
+
+// IndexItemHandler handles GET requests to fetch all items
+func IndexItemHandler(w http.ResponseWriter, r *http.Request) {
+	var items []models.Item
+	selectQuery, ok := scopeByVendor(w, r, QB.Select(strings.Join(item_columns, ", ")).From("items"), "vendor_id")
+	if !ok {
+		return
+	}
+	meta, ok := paginate(w, r, selectQuery, itemListSpec, &items)
+	if !ok {
+		return
+	}
+	if !setDisplayPrices(w, r, itemRefs(items)) {
+		return
+	}
+	sendList(w, meta, items)
+}
+
+// ShowItemHandler handles GET requests to fetch a single item by ID
+func ShowItemHandler(w http.ResponseWriter, r *http.Request) {
+	var item models.Item
+	id := r.PathValue("id")
+	query, args, err := QB.Select(strings.Join(item_columns, ", ")).From("items").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&item, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	item.OptionGroups, err = loadOptionGroups(db, item.ID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !setDisplayPrices(w, r, []*models.Item{&item}) {
+		return
+	}
+	models.ApplyCurrency(&item)
+	utils.SendJSONResponse(w, http.StatusOK, item)
+}
+
+// CreateItemHandler handles POST requests to create a new item
+func CreateItemHandler(w http.ResponseWriter, r *http.Request) {
+	var item models.Item
+	if r.FormValue("name") == "" || r.FormValue("price") == "" || r.FormValue("vendor_id") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Name, price, and vendor_id are required")
+		return
+	}
+
+	vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+	// Items are priced in their vendor's currency
+	currency, err := vendorCurrency(db, vendorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusBadRequest, "Vendor not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error loading vendor currency: "+err.Error())
+		return
+	}
+
+	price, err := models.ParseMoney(r.FormValue("price"), currency)
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid price: "+err.Error())
+		return
+	}
+
+	item.ID = uuid.New() // Generate new UUID
+	item.Name = r.FormValue("name")
+	item.Price = price
+	item.Currency = currency
+	item.VendorID = vendorID // Set vendor_id from request
+	if r.FormValue("category_id") != "" {
+		categoryID, err := uuid.Parse(r.FormValue("category_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid category_id format")
+			return
+		}
+		item.CategoryID = &categoryID
+	}
+	if !validateItemCategory(w, item) {
+		return
+	}
+	item.IsAvailable = true
+	if !parseItemAvailability(w, r, &item) {
+		return
+	}
+
+	// Handle image upload
+	file, fileHeader, err := r.FormFile("img")
+	if err != nil && err != http.ErrMissingFile {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid file")
+		return
+	} else if err == nil {
+		defer file.Close()
+		imageName, err := utils.SaveImageFile(file, "items", fileHeader.Filename) // Save image in the "items" directory
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error saving image")
+			return
+		}
+		item.Img = &imageName // Store image path in the item
+	}
+
+	// Build SQL query for inserting item
+	query, args, err := QB.Insert("items").Columns("id", "vendor_id", "category_id", "name", "price", "currency", "img", "is_available", "stock", "sold_out").Values(item.ID, item.VendorID, item.CategoryID, item.Name, item.Price, item.Currency, item.Img, item.IsAvailable, item.Stock, item.SoldOut).Suffix(fmt.Sprintf("RETURNING %s", strings.Join(item_columns, ", "))).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+
+	// Execute query and scan the result into the item struct
+	if err := db.QueryRowx(query, args...).StructScan(&item); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating item: "+err.Error())
+		return
+	}
+
+	models.ApplyCurrency(&item)
+	utils.SendJSONResponse(w, http.StatusCreated, item)
+}
+
+// UpdateItemHandler handles PUT requests to update an existing item
+func UpdateItemHandler(w http.ResponseWriter, r *http.Request) {
+	var item models.Item
+	id := r.PathValue("id")
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	query, args, err := QB.Select(strings.Join(item_columns, ", ")).From("items").Where("id = ?", id).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = tx.Get(&item, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !authorizeVendor(w, r, item.VendorID) {
+		return
+	}
+	previousVendorID := item.VendorID
+
+	// Update fields if provided
+	if r.FormValue("name") != "" {
+		item.Name = r.FormValue("name")
+	}
+	if r.FormValue("vendor_id") != "" {
+		vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+			return
+		}
+		if !authorizeVendor(w, r, vendorID) {
+			return
+		}
+		item.VendorID = vendorID // Update vendor_id as necessary
+		// A moved item takes its new vendor's currency
+		item.Currency, err = vendorCurrency(tx, vendorID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.HandelError(w, http.StatusBadRequest, "Vendor not found")
+				return
+			}
+			utils.HandelError(w, http.StatusInternalServerError, "Error loading vendor currency: "+err.Error())
+			return
+		}
+	}
+	if r.FormValue("price") != "" {
+		price, err := models.ParseMoney(r.FormValue("price"), item.Currency)
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid price: "+err.Error())
+			return
+		}
+		item.Price = price
+	} else if item.Price, err = item.Price.In(item.Currency); err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid price: "+err.Error()+", send a new price")
+		return
+	}
+	if r.FormValue("category_id") != "" {
+		categoryID, err := uuid.Parse(r.FormValue("category_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid category_id format")
+			return
+		}
+		item.CategoryID = &categoryID
+	} else if _, ok := r.Form["category_id"]; ok {
+		// An empty category_id leaves the item uncategorized
+		item.CategoryID = nil
+	}
+	if !validateItemCategory(w, item) {
+		return
+	}
+	if !parseItemAvailability(w, r, &item) {
+		return
+	}
+	if r.FormValue("img") != "" {
+		img := r.FormValue("img") // Update image as necessary
+		item.Img = &img           // Update image path as necessary
+	}
+
+	// Carts are bound to a single vendor, so the item leaves the carts of its previous vendor
+	var cartIDs []uuid.UUID
+	if item.VendorID != previousVendorID {
+		query, args, err = QB.Delete("cart_items").Where(squirrel.Eq{"item_id": item.ID}).Suffix("RETURNING cart_id").ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return
+		}
+		if err := tx.Select(&cartIDs, query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error removing item from carts: "+err.Error())
+			return
+		}
+	}
+
+	query, args, err = QB.Update("items").
+		Set("name", item.Name).
+		Set("price", item.Price).
+		Set("currency", item.Currency).
+		Set("vendor_id", item.VendorID). // Ensure vendor_id is updated
+		Set("category_id", item.CategoryID).
+		Set("img", item.Img).
+		Set("is_available", item.IsAvailable).
+		Set("stock", item.Stock).
+		Set("sold_out", item.SoldOut).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": item.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(item_columns, ", "))).
+		ToSql()
+
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+
+	if err := tx.QueryRowx(query, args...).StructScan(&item); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating item: "+err.Error())
+		return
+	}
+	for _, cartID := range cartIDs {
+		if err := recalculateCart(tx, cartID); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+			return
+		}
+	}
+	// Carts holding this item are priced from the current item price
+	if err := recalculateCartsWithItem(tx, item.ID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&item)
+	utils.SendJSONResponse(w, http.StatusOK, item)
+}
+
+// parseItemAvailability applies the is_available and stock form fields to item.
+// An empty stock stops tracking it; restocking a sold-out item makes it available again
+// unless is_available is sent as well. Setting is_available by hand clears the sold-out mark.
+func parseItemAvailability(w http.ResponseWriter, r *http.Request, item *models.Item) bool {
+	if r.FormValue("stock") != "" {
+		stock, err := strconv.Atoi(r.FormValue("stock"))
+		if err != nil || stock < 0 {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid stock value")
+			return false
+		}
+		item.Stock = &stock
+		if stock == 0 {
+			item.IsAvailable = false
+			item.SoldOut = true
+		} else if item.SoldOut {
+			item.IsAvailable = true
+			item.SoldOut = false
+		}
+	} else if _, ok := r.Form["stock"]; ok {
+		item.Stock = nil
+	}
+	if r.FormValue("is_available") != "" {
+		available, err := strconv.ParseBool(r.FormValue("is_available"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid is_available format")
+			return false
+		}
+		if available && item.Stock != nil && *item.Stock == 0 {
+			utils.HandelError(w, http.StatusBadRequest, "An item with no stock left cannot be made available")
+			return false
+		}
+		item.IsAvailable = available
+		item.SoldOut = false
+	}
+	return true
+}
+
+// DeleteItemHandler handles DELETE requests to remove an item
+func DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, QB.Select("vendor_id").From("items").Where("id = ?", id)) {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// Remember the carts holding the item, its cart_items rows are removed by the cascade
+	var cartIDs []uuid.UUID
+	query, args, err := QB.Select("cart_id").From("cart_items").Where("item_id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Select(&cartIDs, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error fetching carts: "+err.Error())
+		return
+	}
+
+	query, args, err = QB.Delete("items").Where("id=?", id).Suffix("RETURNING img").ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting item: "+err.Error())
+		return
+	}
+
+	var img *string
+	if err := tx.QueryRowx(query, args...).Scan(&img); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error getting image: "+err.Error())
+		return
+	}
+
+	for _, cartID := range cartIDs {
+		if err := recalculateCart(tx, cartID); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating cart totals: "+err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, "Item deleted")
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// listSpec whitelists what clients may sort and filter a resource by.
+// Keys are the names used in the query string, values the SQL columns they map to.
+type listSpec struct {
+	Sortable    map[string]string
+	Filterable  map[string]string
+	DefaultSort string
+	// TieBreaker keeps the order stable between pages when sort keys are equal
+	TieBreaker []string
+}
+
+var filterParam = regexp.MustCompile(`^filter\[(\w+)\]$`)
+
+// paginate applies the page/per_page or cursor, sort and filter[...] query parameters to base,
+// runs it into dest and returns the listing metadata. It writes the error response and returns false on failure.
+//
+//	GET /items?page=2&per_page=10&sort=-price,name&filter[vendor_id]=<id>
+//	GET /items?cursor=<next_cursor from the previous page>
+func paginate(w http.ResponseWriter, r *http.Request, base squirrel.SelectBuilder, spec listSpec, dest interface{}) (models.ListMeta, bool) {
+	params := r.URL.Query()
+	meta := models.ListMeta{Page: 1, PerPage: defaultPerPage}
+
+	if value := params.Get("per_page"); value != "" {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid per_page value")
+			return meta, false
+		}
+		meta.PerPage = min(perPage, maxPerPage)
+	}
+	offset := 0
+	if value := params.Get("cursor"); value != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+		if err == nil {
+			offset, err = strconv.Atoi(string(decoded))
+		}
+		if err != nil || offset < 0 {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid cursor")
+			return meta, false
+		}
+		meta.Page = offset/meta.PerPage + 1
+	} else if value := params.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid page value")
+			return meta, false
+		}
+		meta.Page = page
+		offset = (page - 1) * meta.PerPage
+	}
+
+	for key, values := range params {
+		match := filterParam.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		column, ok := spec.Filterable[match[1]]
+		if !ok {
+			utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("Filtering by %s is not supported", match[1]))
+			return meta, false
+		}
+		var accepted []string
+		for _, value := range values {
+			accepted = append(accepted, strings.Split(value, ",")...)
+		}
+		if len(accepted) == 1 {
+			base = base.Where(squirrel.Eq{column: accepted[0]})
+		} else {
+			base = base.Where(squirrel.Eq{column: accepted})
+		}
+	}
+
+	query, args, err := QB.Select("COUNT(*)").FromSelect(base, "filtered").ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return meta, false
+	}
+	if err := db.Get(&meta.Total, query, args...); err != nil {
+		if isInvalidFilterValue(err) {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid filter value: "+err.Error())
+			return meta, false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return meta, false
+	}
+
+	sort := params.Get("sort")
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	var orderBy []string
+	for _, key := range strings.Split(sort, ",") {
+		if key == "" {
+			continue
+		}
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			direction = "DESC"
+			key = key[1:]
+		}
+		column, ok := spec.Sortable[key]
+		if !ok {
+			utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("Sorting by %s is not supported", key))
+			return meta, false
+		}
+		orderBy = append(orderBy, column+" "+direction)
+	}
+	orderBy = append(orderBy, spec.TieBreaker...)
+
+	query, args, err = base.
+		OrderBy(orderBy...).
+		Limit(uint64(meta.PerPage)).
+		Offset(uint64(offset)).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return meta, false
+	}
+	if err := db.Select(dest, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return meta, false
+	}
+
+	meta.TotalPages = (meta.Total + meta.PerPage - 1) / meta.PerPage
+	if next := offset + meta.PerPage; next < meta.Total {
+		meta.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(next)))
+	}
+	return meta, true
+}
+
+// isInvalidFilterValue reports whether err is Postgres failing to cast a filter value to its column type,
+// such as a malformed uuid, number, boolean, enum value or date
+func isInvalidFilterValue(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "22P02" || pqErr.Code == "22007" || pqErr.Code == "22008" || pqErr.Code == "22003")
+}
+
+// sendList writes a page of results with its listing metadata, amounts at their currency's precision
+func sendList(w http.ResponseWriter, meta models.ListMeta, data interface{}) {
+	models.ApplyCurrency(data)
+	utils.SendJSONResponse(w, http.StatusOK, models.Response{Meta: meta, Data: data})
+}
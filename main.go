@@ -1,185 +1,300 @@
-package main
-
-import (
-	"errors"
-	"fmt"
-	"intership/controllers"
-	"log"
-	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-
-	"github.com/go-michi/michi"
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
-)
-
-// Middleware to handle CORS
-func enableCors(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000") // Allow your frontend
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true") // Allow credentials (cookies, tokens)
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-func main() {
-	// Print the current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("Current working directory:", cwd)
-
-	// Path to .env file in the root directory
-	envPath := filepath.Join(cwd, ".env")
-	if _, err := os.Stat(envPath); os.IsNotExist(err) {
-		log.Fatalf(".env file does not exist at path: %s", envPath)
-	}
-
-	// Load .env file
-	if err := godotenv.Load(envPath); err != nil {
-		log.Fatal("Error loading .env file:", err)
-	}
-
-	// Check if environment variables are loaded correctly
-	fmt.Println("MIGRATIONS_ROOT:", os.Getenv("MIGRATIONS_ROOT"))
-	fmt.Println("DATABASE_URL:", os.Getenv("DATABASE_URL"))
-
-	// Connect to the database
-	db, err := sqlx.Connect("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-
-	// Perform database migrations
-	migrationsRoot := os.Getenv("MIGRATIONS_ROOT")
-	absMigrationsPath := GetRootPath(migrationsRoot)
-	migrationPath := "file://" + strings.ReplaceAll(filepath.ToSlash(absMigrationsPath), "\\", "/")
-	fmt.Println("Migrations path:", migrationPath)
-
-	mig, err := migrate.New(
-		migrationPath,
-		os.Getenv("DATABASE_URL"),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := mig.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		log.Fatal(err)
-	} else if errors.Is(err, migrate.ErrNoChange) {
-		log.Printf("migrations: %s", err.Error())
-	}
-
-	// Set the database connection in the controllers
-	controllers.SetDB(db)
-
-	// Setup router and routes
-	r := michi.NewRouter()
-
-	// Serve uploaded files
-	r.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads"))))
-
-	// User routes
-	r.Route("/", func(sub *michi.Router) {
-		// User CRUD routes
-		sub.HandleFunc("GET users", controllers.IndexUserHandler)          // GET /users
-		sub.HandleFunc("GET users/{id}", controllers.ShowUserHandler)      // GET /users/{id}
-		sub.HandleFunc("PUT users/{id}", controllers.UpdateUserHandler)    // PUT /users/{id}
-		sub.HandleFunc("DELETE users/{id}", controllers.DeleteUserHandler) // DELETE /users/{id}
-		sub.HandleFunc("POST users/signup", controllers.SignUpHandler)     // POST /users/signup
-		sub.HandleFunc("POST users/login", controllers.LoginHandler)       // POST /users/login
-
-		// Vendor admin routes
-		sub.HandleFunc("POST vendor_admins", controllers.CreateVendorAdminHandler)                         // POST /vendor_admins
-		sub.HandleFunc("GET vendor_admins", controllers.IndexVendorAdminsHandler)                          // GET /vendor_admins
-		sub.HandleFunc("GET vendor_admins/{user_id}/{vendor_id}", controllers.ShowVendorAdminHandler)      // GET /vendor_admins/{user_id}/{vendor_id}
-		sub.HandleFunc("DELETE vendor_admins/{user_id}/{vendor_id}", controllers.DeleteVendorAdminHandler) // DELETE /vendor_admins/{user_id}/{vendor_id}
-		sub.HandleFunc("PUT vendor_admins/{user_id}/{vendor_id}", controllers.UpdateVendorAdminHandler)
-
-		// Vendor routes
-		sub.HandleFunc("GET vendors", controllers.IndexVendorHandler)          // GET /vendors
-		sub.HandleFunc("GET vendors/{id}", controllers.ShowVendorHandler)      // GET /vendors/{id}
-		sub.HandleFunc("PUT vendors/{id}", controllers.UpdateVendorHandler)    // PUT /vendors/{id}
-		sub.HandleFunc("DELETE vendors/{id}", controllers.DeleteVendorHandler) // DELETE /vendors/{id}
-		sub.HandleFunc("POST vendors/signup", controllers.SignUpVendorHandler) // POST /vendors/signup
-
-		// User roles routes
-		sub.HandleFunc("GET user_roles", controllers.IndexUserRolesHandler)                        // GET /user_roles
-		sub.HandleFunc("GET user_roles/{user_id}/{role_id}", controllers.ShowUserRoleHandler)      // GET /user_roles/{user_id}/{role_id}
-		sub.HandleFunc("POST user_roles", controllers.CreateUserRoleHandler)                       // POST /user_roles
-		sub.HandleFunc("DELETE user_roles/{user_id}/{role_id}", controllers.DeleteUserRoleHandler) // DELETE /user_roles/{user_id}/{role_id}
-		sub.HandleFunc("PUT user_roles", controllers.UpdateUserRoleHandler)                        // PUT /user_roles/{user_id}
-
-		// Item routes
-		sub.HandleFunc("POST items", controllers.CreateItemHandler)        // POST /items
-		sub.HandleFunc("GET items", controllers.IndexItemHandler)          // GET /items
-		sub.HandleFunc("GET items/{id}", controllers.ShowItemHandler)      // GET /items/{id}
-		sub.HandleFunc("PUT items/{id}", controllers.UpdateItemHandler)    // PUT /items/{id}
-		sub.HandleFunc("DELETE items/{id}", controllers.DeleteItemHandler) // DELETE /items/{id}
-		//tables routes
-		sub.HandleFunc("GET tables", controllers.IndexTableHandler)          // GET /tables
-		sub.HandleFunc("GET tables/{id}", controllers.ShowTableHandler)      // GET /tables/{id}
-		sub.HandleFunc("POST tables", controllers.CreateTableHandler)        // POST /tables
-		sub.HandleFunc("PUT tables/{id}", controllers.UpdateTableHandler)    // PUT /tables/{id}
-		sub.HandleFunc("DELETE tables/{id}", controllers.DeleteTableHandler) // DELETE /tables/{id}
-
-		//ordersrouts
-		sub.HandleFunc("POST orders", controllers.CreateOrderHandler)        // POST /orders
-		sub.HandleFunc("GET orders", controllers.IndexOrderHandler)          // GET /orders
-		sub.HandleFunc("GET orders/{id}", controllers.ShowOrderHandler)      // GET /orders/{id}
-		sub.HandleFunc("PUT orders/{id}", controllers.UpdateOrderHandler)    // PUT /orders/{id}
-		sub.HandleFunc("DELETE orders/{id}", controllers.DeleteOrderHandler) // DELETE /orders/
-
-		// Order Items CRUD routes
-		sub.HandleFunc("POST order_items", controllers.CreateOrderItemHandler)        // POST /order_items
-		sub.HandleFunc("GET order_items", controllers.IndexOrderItemHandler)          // GET /order_items
-		sub.HandleFunc("GET order_items/{id}", controllers.ShowOrderItemHandler)      // GET /order_items/{id}
-		sub.HandleFunc("PUT order_items/{id}", controllers.UpdateOrderItemHandler)    // PUT /order_items/{id}
-		sub.HandleFunc("DELETE order_items/{id}", controllers.DeleteOrderItemHandler) // DELETE /order_items/{id}
-
-		// Carts CRUD routes
-		sub.HandleFunc("POST carts", controllers.CreateCartHandler)        // POST /carts
-		sub.HandleFunc("GET carts", controllers.IndexCartHandler)          // GET /carts
-		sub.HandleFunc("GET carts/{id}", controllers.ShowCartHandler)      // GET /carts/{id}
-		sub.HandleFunc("PUT carts/{id}", controllers.UpdateCartHandler)    // PUT /carts/{id}
-		sub.HandleFunc("DELETE carts/{id}", controllers.DeleteCartHandler) // DELETE /carts/{id}
-
-		sub.HandleFunc("GET cart_items", controllers.IndexCartItemsHandler)                        // GET /cart_items
-		sub.HandleFunc("GET cart_items/{cart_id}/{item_id}", controllers.ShowCartItemHandler)      // GET /cart_items/{cart_id}/{item_id}
-		sub.HandleFunc("POST cart_items", controllers.CreateCartItemHandler)                       // POST /cart_items
-		sub.HandleFunc("PUT cart_items/{cart_id}/{item_id}", controllers.UpdateCartItemHandler)    // PUT /cart_items/{cart_id}/{item_id}
-		sub.HandleFunc("DELETE cart_items/{cart_id}/{item_id}", controllers.DeleteCartItemHandler) // DELETE /cart_items/{cart_id}/{item_id}
-
-	})
-
-	// Wrap the router with the CORS middleware
-	corsRouter := enableCors(r)
-
-	// Start the server with CORS-enabled routes
-	fmt.Println("Starting server on port 8000")
-	if err := http.ListenAndServe(":8000", corsRouter); err != nil {
-		log.Fatal("ListenAndServe: ", err)
-	}
-}
-
-// GetRootPath resolves the absolute path of a given directory relative to the project root
-func GetRootPath(dir string) string {
-	absPath, err := filepath.Abs(dir)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return absPath
-}
+package main
+
+import (
+	"errors"
+	"fmt"
+	"intership/controllers"
+	"intership/models"
+	"intership/payments"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-michi/michi"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+)
+
+// Middleware to handle CORS
+func enableCors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000") // Allow your frontend
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Credentials", "true") // Allow credentials (cookies, tokens)
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	// Print the current working directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Current working directory:", cwd)
+
+	// Path to .env file in the root directory
+	envPath := filepath.Join(cwd, ".env")
+	if _, err := os.Stat(envPath); os.IsNotExist(err) {
+		log.Fatalf(".env file does not exist at path: %s", envPath)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(envPath); err != nil {
+		log.Fatal("Error loading .env file:", err)
+	}
+
+	// Check if environment variables are loaded correctly
+	fmt.Println("MIGRATIONS_ROOT:", os.Getenv("MIGRATIONS_ROOT"))
+	fmt.Println("DATABASE_URL:", os.Getenv("DATABASE_URL"))
+
+	// Connect to the database
+	db, err := sqlx.Connect("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Perform database migrations
+	migrationsRoot := os.Getenv("MIGRATIONS_ROOT")
+	absMigrationsPath := GetRootPath(migrationsRoot)
+	migrationPath := "file://" + strings.ReplaceAll(filepath.ToSlash(absMigrationsPath), "\\", "/")
+	fmt.Println("Migrations path:", migrationPath)
+
+	mig, err := migrate.New(
+		migrationPath,
+		os.Getenv("DATABASE_URL"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := mig.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		log.Fatal(err)
+	} else if errors.Is(err, migrate.ErrNoChange) {
+		log.Printf("migrations: %s", err.Error())
+	}
+
+	// Set the database connection in the controllers
+	controllers.SetDB(db)
+
+	// Set the payment provider orders are charged through. The fake provider approves almost any
+	// token, so it has to be asked for and only runs in development.
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "fake":
+		if os.Getenv("APP_ENV") != "development" {
+			log.Fatal("PAYMENT_PROVIDER=fake is only allowed with APP_ENV=development")
+		}
+		controllers.SetPaymentProvider(payments.NewFake(os.Getenv("PAYMENT_WEBHOOK_SECRET")))
+	case "":
+		log.Fatal("PAYMENT_PROVIDER is not set")
+	default:
+		log.Fatalf("Unknown PAYMENT_PROVIDER %q", provider)
+	}
+
+	// Start listening for the order and table notifications behind the live streams
+	if err := controllers.ListenForEvents(os.Getenv("DATABASE_URL")); err != nil {
+		log.Fatal(err)
+	}
+
+	// Setup router and routes
+	r := newRouter(controllers.AuthMiddleware)
+
+	// Wrap the router with the CORS middleware
+	corsRouter := enableCors(r)
+
+	// Start the server with CORS-enabled routes
+	fmt.Println("Starting server on port 8000")
+	if err := http.ListenAndServe(":8000", corsRouter); err != nil {
+		log.Fatal("ListenAndServe: ", err)
+	}
+}
+
+// newRouter sets up the routes and the role policy guarding each one.
+// authenticate stores the signed-in user in the request context.
+func newRouter(authenticate func(http.Handler) http.Handler) *michi.Router {
+	r := michi.NewRouter()
+
+	// Serve uploaded files
+	r.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads"))))
+
+	// User routes
+	r.Route("/", func(sub *michi.Router) {
+		// Public routes
+		sub.HandleFunc("POST users/signup", controllers.SignUpHandler)        // POST /users/signup
+		sub.HandleFunc("POST users/login", controllers.LoginHandler)          // POST /users/login
+		sub.HandleFunc("POST users/refresh", controllers.RefreshTokenHandler) // POST /users/refresh
+		sub.HandleFunc("POST users/logout", controllers.LogoutHandler)        // POST /users/logout
+
+		// Called by the payment provider, authenticated by the webhook signature
+		sub.HandleFunc("POST payments/webhook", controllers.PaymentWebhookHandler) // POST /payments/webhook
+
+		// Routes below require a valid access token
+		sub.Group(func(auth *michi.Router) {
+			auth.Use(authenticate)
+
+			// Route policies: who may call each endpoint
+			adminOnly := controllers.RequireRoles(models.RoleAdmin)
+			vendorOnly := controllers.RequireRoles(models.RoleVendor)
+			customerOnly := controllers.RequireRoles(models.RoleCustomer)
+			customerOrAdmin := controllers.RequireRoles(models.RoleCustomer, models.RoleAdmin)
+			vendorOrAdmin := controllers.RequireRoles(models.RoleVendor, models.RoleAdmin)
+			anyRole := controllers.RequireRoles(models.RoleAdmin, models.RoleVendor, models.RoleCustomer)
+			selfOrAdmin := controllers.RequireSelfOrRoles("id", models.RoleAdmin)
+			cartOwnerOrAdmin := controllers.RequireSelfOrRoles("cart_id", models.RoleAdmin)
+
+			// User CRUD routes
+			auth.With(adminOnly).HandleFunc("GET users", controllers.IndexUserHandler)            // GET /users
+			auth.With(selfOrAdmin).HandleFunc("GET users/{id}", controllers.ShowUserHandler)      // GET /users/{id}
+			auth.With(selfOrAdmin).HandleFunc("PUT users/{id}", controllers.UpdateUserHandler)    // PUT /users/{id}
+			auth.With(selfOrAdmin).HandleFunc("DELETE users/{id}", controllers.DeleteUserHandler) // DELETE /users/{id}
+			auth.With(anyRole).HandleFunc("POST users/logout-all", controllers.LogoutAllHandler)  // POST /users/logout-all
+
+			// Vendor admin routes
+			auth.With(adminOnly).HandleFunc("POST vendor_admins", controllers.CreateVendorAdminHandler)                         // POST /vendor_admins
+			auth.With(adminOnly).HandleFunc("GET vendor_admins", controllers.IndexVendorAdminsHandler)                          // GET /vendor_admins
+			auth.With(adminOnly).HandleFunc("GET vendor_admins/{user_id}/{vendor_id}", controllers.ShowVendorAdminHandler)      // GET /vendor_admins/{user_id}/{vendor_id}
+			auth.With(adminOnly).HandleFunc("DELETE vendor_admins/{user_id}/{vendor_id}", controllers.DeleteVendorAdminHandler) // DELETE /vendor_admins/{user_id}/{vendor_id}
+			auth.With(adminOnly).HandleFunc("PUT vendor_admins/{user_id}/{vendor_id}", controllers.UpdateVendorAdminHandler)
+
+			// Vendor routes
+			auth.With(anyRole).HandleFunc("GET vendors", controllers.IndexVendorHandler)                // GET /vendors
+			auth.With(anyRole).HandleFunc("GET vendors/{id}", controllers.ShowVendorHandler)            // GET /vendors/{id}
+			auth.With(vendorOrAdmin).HandleFunc("PUT vendors/{id}", controllers.UpdateVendorHandler)    // PUT /vendors/{id}
+			auth.With(adminOnly).HandleFunc("DELETE vendors/{id}", controllers.DeleteVendorHandler)     // DELETE /vendors/{id}
+			auth.With(vendorOrAdmin).HandleFunc("POST vendors/signup", controllers.SignUpVendorHandler) // POST /vendors/signup
+			auth.With(anyRole).HandleFunc("GET vendors/{id}/menu", controllers.VendorMenuHandler)       // GET /vendors/{id}/menu
+			auth.With(vendorOrAdmin).HandleFunc("PUT vendors/{id}/hours", controllers.UpdateVendorHoursHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST vendors/{id}/closures", controllers.CreateVendorClosureHandler)
+			auth.With(vendorOrAdmin).HandleFunc("DELETE vendors/{id}/closures/{closure_id}", controllers.DeleteVendorClosureHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST vendors/{id}/pause", controllers.PauseVendorHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST vendors/{id}/resume", controllers.ResumeVendorHandler)
+			auth.With(anyRole).HandleFunc("GET vendors/{id}/availability", controllers.VendorAvailabilityHandler)
+			auth.With(anyRole).HandleFunc("GET vendors/{id}/floor-plan", controllers.VendorFloorPlanHandler)
+			auth.With(vendorOrAdmin).HandleFunc("GET vendors/{id}/service-calls", controllers.IndexServiceCallsHandler)
+			auth.With(vendorOrAdmin).HandleFunc("GET vendors/{id}/table-events", controllers.StreamTableEventsHandler)
+
+			// Category routes
+			auth.With(anyRole).HandleFunc("GET categories", controllers.IndexCategoryHandler)             // GET /categories
+			auth.With(anyRole).HandleFunc("GET categories/{id}", controllers.ShowCategoryHandler)         // GET /categories/{id}
+			auth.With(vendorOnly).HandleFunc("POST categories", controllers.CreateCategoryHandler)        // POST /categories
+			auth.With(vendorOnly).HandleFunc("PUT categories/{id}", controllers.UpdateCategoryHandler)    // PUT /categories/{id}
+			auth.With(vendorOnly).HandleFunc("DELETE categories/{id}", controllers.DeleteCategoryHandler) // DELETE /categories/{id}
+
+			// User roles routes
+			auth.With(adminOnly).HandleFunc("GET user_roles", controllers.IndexUserRolesHandler)                        // GET /user_roles
+			auth.With(adminOnly).HandleFunc("GET user_roles/{user_id}/{role_id}", controllers.ShowUserRoleHandler)      // GET /user_roles/{user_id}/{role_id}
+			auth.With(adminOnly).HandleFunc("POST user_roles", controllers.CreateUserRoleHandler)                       // POST /user_roles
+			auth.With(adminOnly).HandleFunc("DELETE user_roles/{user_id}/{role_id}", controllers.DeleteUserRoleHandler) // DELETE /user_roles/{user_id}/{role_id}
+			auth.With(adminOnly).HandleFunc("PUT user_roles", controllers.UpdateUserRoleHandler)                        // PUT /user_roles/{user_id}
+
+			// Item routes
+			auth.With(vendorOnly).HandleFunc("POST items", controllers.CreateItemHandler)        // POST /items
+			auth.With(anyRole).HandleFunc("GET items", controllers.IndexItemHandler)             // GET /items
+			auth.With(anyRole).HandleFunc("GET items/search", controllers.SearchItemsHandler)    // GET /items/search
+			auth.With(anyRole).HandleFunc("GET items/{id}", controllers.ShowItemHandler)         // GET /items/{id}
+			auth.With(vendorOnly).HandleFunc("PUT items/{id}", controllers.UpdateItemHandler)    // PUT /items/{id}
+			auth.With(vendorOnly).HandleFunc("DELETE items/{id}", controllers.DeleteItemHandler) // DELETE /items/{id}
+
+			// Item option routes
+			auth.With(vendorOnly).HandleFunc("POST option_groups", controllers.CreateOptionGroupHandler)        // POST /option_groups
+			auth.With(vendorOnly).HandleFunc("PUT option_groups/{id}", controllers.UpdateOptionGroupHandler)    // PUT /option_groups/{id}
+			auth.With(vendorOnly).HandleFunc("DELETE option_groups/{id}", controllers.DeleteOptionGroupHandler) // DELETE /option_groups/{id}
+			auth.With(vendorOnly).HandleFunc("POST options", controllers.CreateOptionHandler)                   // POST /options
+			auth.With(vendorOnly).HandleFunc("PUT options/{id}", controllers.UpdateOptionHandler)               // PUT /options/{id}
+			auth.With(vendorOnly).HandleFunc("DELETE options/{id}", controllers.DeleteOptionHandler)            // DELETE /options/{id}
+			//tables routes
+			auth.With(anyRole).HandleFunc("GET tables", controllers.IndexTableHandler)             // GET /tables
+			auth.With(anyRole).HandleFunc("GET tables/{id}", controllers.ShowTableHandler)         // GET /tables/{id}
+			auth.With(vendorOnly).HandleFunc("POST tables", controllers.CreateTableHandler)        // POST /tables
+			auth.With(vendorOnly).HandleFunc("PUT tables/{id}", controllers.UpdateTableHandler)    // PUT /tables/{id}
+			auth.With(vendorOnly).HandleFunc("DELETE tables/{id}", controllers.DeleteTableHandler) // DELETE /tables/{id}
+			auth.With(customerOnly).HandleFunc("POST tables/{id}/seat", controllers.SeatTableHandler)
+			auth.With(customerOnly).HandleFunc("POST tables/{id}/call-service", controllers.CallServiceTableHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST tables/{id}/service-done", controllers.ServiceDoneTableHandler)
+			auth.With(anyRole).HandleFunc("POST tables/{id}/release", controllers.ReleaseTableHandler)
+			auth.With(vendorOrAdmin).HandleFunc("GET tables/{id}/sessions", controllers.IndexTableSessionsHandler)
+			auth.With(vendorOrAdmin).HandleFunc("GET tables/{id}/orders", controllers.IndexTableOrdersHandler)
+			auth.With(vendorOrAdmin).HandleFunc("GET tables/{id}/qr.png", controllers.TableQRCodeHandler)
+			auth.With(vendorOrAdmin).HandleFunc("GET tables/{id}/checkin-token", controllers.ShowTableCheckinTokenHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST tables/{id}/checkin-token/rotate", controllers.RotateTableCheckinTokenHandler)
+			auth.With(customerOnly).HandleFunc("POST tables/checkin", controllers.CheckinTableHandler)
+
+			//reservations routes
+			auth.With(anyRole).HandleFunc("GET reservations", controllers.IndexReservationsHandler)
+			auth.With(anyRole).HandleFunc("GET reservations/{id}", controllers.ShowReservationHandler)
+			auth.With(customerOnly).HandleFunc("POST reservations", controllers.CreateReservationHandler)
+			auth.With(anyRole).HandleFunc("POST reservations/{id}/cancel", controllers.CancelReservationHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST reservations/{id}/no-show", controllers.NoShowReservationHandler)
+
+			//ordersrouts
+			auth.With(customerOrAdmin).HandleFunc("POST orders", controllers.CreateOrderHandler)   // POST /orders
+			auth.With(anyRole).HandleFunc("GET orders", controllers.IndexOrderHandler)             // GET /orders
+			auth.With(anyRole).HandleFunc("GET orders/stream", controllers.StreamOrdersHandler)    // GET /orders/stream
+			auth.With(anyRole).HandleFunc("GET orders/{id}", controllers.ShowOrderHandler)         // GET /orders/{id}
+			auth.With(vendorOrAdmin).HandleFunc("PUT orders/{id}", controllers.UpdateOrderHandler) // PUT /orders/{id}
+			auth.With(adminOnly).HandleFunc("DELETE orders/{id}", controllers.DeleteOrderHandler)  // DELETE /orders/
+
+			// Order status transitions
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/accept", controllers.TransitionOrderHandler(models.Accepted))    // POST /orders/{id}/accept
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/reject", controllers.TransitionOrderHandler(models.Rejected))    // POST /orders/{id}/reject
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/prepare", controllers.TransitionOrderHandler(models.Preparing))  // POST /orders/{id}/prepare
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/ready", controllers.TransitionOrderHandler(models.Ready))        // POST /orders/{id}/ready
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/serve", controllers.TransitionOrderHandler(models.Served))       // POST /orders/{id}/serve
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/complete", controllers.TransitionOrderHandler(models.Completed)) // POST /orders/{id}/complete
+			auth.With(anyRole).HandleFunc("POST orders/{id}/cancel", controllers.TransitionOrderHandler(models.Cancelled))         // POST /orders/{id}/cancel
+
+			// Payment routes
+			auth.With(customerOnly).HandleFunc("POST orders/{id}/pay", controllers.PayOrderHandler)
+			auth.With(vendorOrAdmin).HandleFunc("POST orders/{id}/refund", controllers.RefundOrderHandler)
+			auth.With(anyRole).HandleFunc("GET orders/{id}/payments", controllers.IndexOrderPaymentsHandler)
+
+			// Order Items CRUD routes
+			auth.With(customerOnly).HandleFunc("POST order_items", controllers.CreateOrderItemHandler)         // POST /order_items
+			auth.With(anyRole).HandleFunc("GET order_items", controllers.IndexOrderItemHandler)                // GET /order_items
+			auth.With(anyRole).HandleFunc("GET order_items/{id}", controllers.ShowOrderItemHandler)            // GET /order_items/{id}
+			auth.With(vendorOrAdmin).HandleFunc("PUT order_items/{id}", controllers.UpdateOrderItemHandler)    // PUT /order_items/{id}
+			auth.With(vendorOrAdmin).HandleFunc("DELETE order_items/{id}", controllers.DeleteOrderItemHandler) // DELETE /order_items/{id}
+
+			// Carts CRUD routes
+			auth.With(customerOrAdmin).HandleFunc("POST carts", controllers.CreateCartHandler)             // POST /carts
+			auth.With(vendorOrAdmin).HandleFunc("GET carts", controllers.IndexCartHandler)                 // GET /carts
+			auth.With(selfOrAdmin).HandleFunc("GET carts/{id}", controllers.ShowCartHandler)               // GET /carts/{id}
+			auth.With(selfOrAdmin).HandleFunc("PUT carts/{id}", controllers.UpdateCartHandler)             // PUT /carts/{id}
+			auth.With(selfOrAdmin).HandleFunc("DELETE carts/{id}", controllers.DeleteCartHandler)          // DELETE /carts/{id}
+			auth.With(selfOrAdmin).HandleFunc("POST carts/{id}/checkout", controllers.CheckoutCartHandler) // POST /carts/{id}/checkout
+			auth.With(anyRole).HandleFunc("GET me/cart", controllers.ShowMyCartHandler)                    // GET /me/cart
+
+			auth.With(adminOnly).HandleFunc("GET cart_items", controllers.IndexCartItemsHandler)                          // GET /cart_items
+			auth.With(cartOwnerOrAdmin).HandleFunc("GET cart_items/{cart_id}/{id}", controllers.ShowCartItemHandler)      // GET /cart_items/{cart_id}/{id}
+			auth.With(cartOwnerOrAdmin).HandleFunc("POST cart_items/{cart_id}", controllers.CreateCartItemHandler)        // POST /cart_items/{cart_id}
+			auth.With(cartOwnerOrAdmin).HandleFunc("PUT cart_items/{cart_id}/{id}", controllers.UpdateCartItemHandler)    // PUT /cart_items/{cart_id}/{id}
+			auth.With(cartOwnerOrAdmin).HandleFunc("DELETE cart_items/{cart_id}/{id}", controllers.DeleteCartItemHandler) // DELETE /cart_items/{cart_id}/{id}
+
+			// Exchange rates for showing approximate prices in another currency
+			auth.With(anyRole).HandleFunc("GET exchange-rates", controllers.IndexExchangeRatesHandler)
+			auth.With(adminOnly).HandleFunc("PUT exchange-rates/{base}/{quote}", controllers.PutExchangeRateHandler)
+			auth.With(adminOnly).HandleFunc("DELETE exchange-rates/{base}/{quote}", controllers.DeleteExchangeRateHandler)
+		})
+	})
+	return r
+}
+
+// GetRootPath resolves the absolute path of a given directory relative to the project root
+func GetRootPath(dir string) string {
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return absPath
+}
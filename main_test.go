@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"intership/controllers"
+	"intership/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	adminOnly       = []string{models.RoleAdmin}
+	vendorOnly      = []string{models.RoleVendor}
+	customerOnly    = []string{models.RoleCustomer}
+	customerOrAdmin = []string{models.RoleCustomer, models.RoleAdmin}
+	vendorOrAdmin   = []string{models.RoleVendor, models.RoleAdmin}
+	anyRole         = []string{models.RoleAdmin, models.RoleVendor, models.RoleCustomer}
+	// Routes keyed by a user or cart id let the owner through; the paths below use someone else's id
+	selfOrAdmin = adminOnly
+)
+
+// ID stands for the id path values in routePolicies
+const ID = "00000000-0000-0000-0000-0000000000aa"
+
+// routePolicies lists every authenticated route with the roles allowed to call it
+var routePolicies = []struct {
+	method, path string
+	allowed      []string
+}{
+	{"GET", "/users", adminOnly},
+	{"GET", "/users/ID", selfOrAdmin},
+	{"PUT", "/users/ID", selfOrAdmin},
+	{"DELETE", "/users/ID", selfOrAdmin},
+	{"POST", "/users/logout-all", anyRole},
+	{"POST", "/vendor_admins", adminOnly},
+	{"GET", "/vendor_admins", adminOnly},
+	{"GET", "/vendor_admins/ID/ID", adminOnly},
+	{"DELETE", "/vendor_admins/ID/ID", adminOnly},
+	{"PUT", "/vendor_admins/ID/ID", adminOnly},
+	{"GET", "/vendors", anyRole},
+	{"GET", "/vendors/ID", anyRole},
+	{"PUT", "/vendors/ID", vendorOrAdmin},
+	{"DELETE", "/vendors/ID", adminOnly},
+	{"POST", "/vendors/signup", vendorOrAdmin},
+	{"GET", "/vendors/ID/menu", anyRole},
+	{"PUT", "/vendors/ID/hours", vendorOrAdmin},
+	{"POST", "/vendors/ID/closures", vendorOrAdmin},
+	{"DELETE", "/vendors/ID/closures/ID", vendorOrAdmin},
+	{"POST", "/vendors/ID/pause", vendorOrAdmin},
+	{"POST", "/vendors/ID/resume", vendorOrAdmin},
+	{"GET", "/vendors/ID/availability", anyRole},
+	{"GET", "/vendors/ID/floor-plan", anyRole},
+	{"GET", "/vendors/ID/service-calls", vendorOrAdmin},
+	{"GET", "/vendors/ID/table-events", vendorOrAdmin},
+	{"GET", "/categories", anyRole},
+	{"GET", "/categories/ID", anyRole},
+	{"POST", "/categories", vendorOnly},
+	{"PUT", "/categories/ID", vendorOnly},
+	{"DELETE", "/categories/ID", vendorOnly},
+	{"GET", "/user_roles", adminOnly},
+	{"GET", "/user_roles/ID/1", adminOnly},
+	{"POST", "/user_roles", adminOnly},
+	{"DELETE", "/user_roles/ID/1", adminOnly},
+	{"PUT", "/user_roles", adminOnly},
+	{"POST", "/items", vendorOnly},
+	{"GET", "/items", anyRole},
+	{"GET", "/items/search", anyRole},
+	{"GET", "/items/ID", anyRole},
+	{"PUT", "/items/ID", vendorOnly},
+	{"DELETE", "/items/ID", vendorOnly},
+	{"POST", "/option_groups", vendorOnly},
+	{"PUT", "/option_groups/ID", vendorOnly},
+	{"DELETE", "/option_groups/ID", vendorOnly},
+	{"POST", "/options", vendorOnly},
+	{"PUT", "/options/ID", vendorOnly},
+	{"DELETE", "/options/ID", vendorOnly},
+	{"GET", "/tables", anyRole},
+	{"GET", "/tables/ID", anyRole},
+	{"POST", "/tables", vendorOnly},
+	{"PUT", "/tables/ID", vendorOnly},
+	{"DELETE", "/tables/ID", vendorOnly},
+	{"POST", "/tables/ID/seat", customerOnly},
+	{"POST", "/tables/ID/call-service", customerOnly},
+	{"POST", "/tables/ID/service-done", vendorOrAdmin},
+	{"POST", "/tables/ID/release", anyRole},
+	{"GET", "/tables/ID/sessions", vendorOrAdmin},
+	{"GET", "/tables/ID/orders", vendorOrAdmin},
+	{"GET", "/tables/ID/qr.png", vendorOrAdmin},
+	{"GET", "/tables/ID/checkin-token", vendorOrAdmin},
+	{"POST", "/tables/ID/checkin-token/rotate", vendorOrAdmin},
+	{"POST", "/tables/checkin", customerOnly},
+	{"GET", "/reservations", anyRole},
+	{"GET", "/reservations/ID", anyRole},
+	{"POST", "/reservations", customerOnly},
+	{"POST", "/reservations/ID/cancel", anyRole},
+	{"POST", "/reservations/ID/no-show", vendorOrAdmin},
+	{"POST", "/orders", customerOrAdmin},
+	{"GET", "/orders", anyRole},
+	{"GET", "/orders/stream", anyRole},
+	{"GET", "/orders/ID", anyRole},
+	{"PUT", "/orders/ID", vendorOrAdmin},
+	{"DELETE", "/orders/ID", adminOnly},
+	{"POST", "/orders/ID/accept", vendorOrAdmin},
+	{"POST", "/orders/ID/reject", vendorOrAdmin},
+	{"POST", "/orders/ID/prepare", vendorOrAdmin},
+	{"POST", "/orders/ID/ready", vendorOrAdmin},
+	{"POST", "/orders/ID/serve", vendorOrAdmin},
+	{"POST", "/orders/ID/complete", vendorOrAdmin},
+	{"POST", "/orders/ID/cancel", anyRole},
+	{"POST", "/orders/ID/pay", customerOnly},
+	{"POST", "/orders/ID/refund", vendorOrAdmin},
+	{"GET", "/orders/ID/payments", anyRole},
+	{"POST", "/order_items", customerOnly},
+	{"GET", "/order_items", anyRole},
+	{"GET", "/order_items/ID", anyRole},
+	{"PUT", "/order_items/ID", vendorOrAdmin},
+	{"DELETE", "/order_items/ID", vendorOrAdmin},
+	{"POST", "/carts", customerOrAdmin},
+	{"GET", "/carts", vendorOrAdmin},
+	{"GET", "/carts/ID", selfOrAdmin},
+	{"PUT", "/carts/ID", selfOrAdmin},
+	{"DELETE", "/carts/ID", selfOrAdmin},
+	{"POST", "/carts/ID/checkout", selfOrAdmin},
+	{"GET", "/me/cart", anyRole},
+	{"GET", "/cart_items", adminOnly},
+	{"GET", "/cart_items/ID/ID", selfOrAdmin},
+	{"POST", "/cart_items/ID", selfOrAdmin},
+	{"PUT", "/cart_items/ID/ID", selfOrAdmin},
+	{"DELETE", "/cart_items/ID/ID", selfOrAdmin},
+	{"GET", "/exchange-rates", anyRole},
+	{"PUT", "/exchange-rates/USD/EUR", adminOnly},
+	{"DELETE", "/exchange-rates/USD/EUR", adminOnly},
+}
+
+// passThrough stands in for AuthMiddleware; the tests put the user in the request context themselves
+func passThrough(next http.Handler) http.Handler { return next }
+
+func userWithRole(role string) models.User {
+	return models.User{ID: uuid.New(), Roles: []models.Role{{Name: role}}}
+}
+
+// deniedByPolicy reports whether the route policy turned the user away. Requests it lets through
+// reach handlers that need the database, which the tests do not have, so their panics are ignored.
+func deniedByPolicy(router http.Handler, method, path string, user models.User) (denied bool) {
+	rec := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(controllers.ContextWithUser(context.Background(), user))
+	cancel() // event streams return at once instead of waiting for events
+	req := httptest.NewRequest(method, path, nil).WithContext(ctx)
+	defer func() {
+		if recover() != nil {
+			denied = false
+		}
+	}()
+	router.ServeHTTP(rec, req)
+	return rec.Code == http.StatusForbidden && strings.Contains(rec.Body.String(), "You are not allowed to access this resource")
+}
+
+func TestRoutePolicies(t *testing.T) {
+	router := newRouter(passThrough)
+	for _, route := range routePolicies {
+		path := strings.ReplaceAll(route.path, "ID", ID)
+		for _, role := range anyRole {
+			allowed := false
+			for _, name := range route.allowed {
+				allowed = allowed || name == role
+			}
+			if denied := deniedByPolicy(router, route.method, path, userWithRole(role)); denied == allowed {
+				t.Errorf("%s %s as %s: denied = %v, want %v", route.method, route.path, role, denied, !allowed)
+			}
+		}
+	}
+}
+
+func TestOwnerRoutesAllowOwner(t *testing.T) {
+	router := newRouter(passThrough)
+	owner := userWithRole(models.RoleCustomer)
+	stranger := userWithRole(models.RoleCustomer)
+	for _, path := range []string{
+		"GET /users/ID",
+		"PUT /users/ID",
+		"DELETE /users/ID",
+		"GET /carts/ID",
+		"PUT /carts/ID",
+		"DELETE /carts/ID",
+		"POST /carts/ID/checkout",
+		"POST /cart_items/ID",
+		"GET /cart_items/ID/" + ID,
+		"PUT /cart_items/ID/" + ID,
+		"DELETE /cart_items/ID/" + ID,
+	} {
+		method, pattern, _ := strings.Cut(path, " ")
+		pattern = strings.Replace(pattern, "ID", owner.ID.String(), 1)
+		if deniedByPolicy(router, method, pattern, owner) {
+			t.Errorf("%s as owner: denied, want allowed", path)
+		}
+		if !deniedByPolicy(router, method, pattern, stranger) {
+			t.Errorf("%s as another customer: allowed, want denied", path)
+		}
+	}
+}
@@ -1,116 +1,476 @@
-package models
-
-import (
-	"time"
-
-	"github.com/google/uuid"
-)
-
-type User struct {
-	ID         uuid.UUID `db:"id"        json:"id"`
-	Name       string    `db:"name"      json:"name"`
-	Email      string    `db:"email"     json:"email"`
-	Phone      string    `db:"phone"     json:"phone"`
-	Img        *string   `db:"img"       json:"img"`
-	Password   string    `db:"password"  json:"-"`
-	Created_at time.Time `db:"created_at" json:"created_at"`
-	Updated_at time.Time `db:"updated_at" json:"updated_at"`
-	// Add roles field to retrieve associated roles
-	Roles []Role `db:"-" json:"roles,omitempty"` // Not stored in 'users' table, but useful for response
-}
-
-type Role struct {
-	ID         int       `db:"id"        json:"id"`
-	Name       string    `db:"name"      json:"name"`
-	Created_at time.Time `db:"created_at" json:"created_at"`
-	Updated_at time.Time `db:"updated_at" json:"updated_at"`
-}
-
-type UserRole struct {
-	UserID uuid.UUID `db:"user_id" json:"user_id"`
-	RoleID int       `db:"role_id" json:"role_id"`
-}
-
-// Response struct for consistent API responses
-type Response struct {
-	Meta interface{} `json:"meta"`
-	Data interface{} `json:"data"`
-}
-
-// Vendor struct to store vendor information
-type Vendor struct {
-	ID          uuid.UUID `db:"id"        json:"id"`
-	Name        string    `db:"name"      json:"name"`
-	Img         *string   `db:"img"       json:"img"`
-	Description string    `db:"description" json:"description"`
-	Created_at  time.Time `db:"created_at" json:"created_at"`
-	Updated_at  time.Time `db:"updated_at" json:"updated_at"`
-}
-
-// Item represents an item in the store
-type Item struct {
-	ID        uuid.UUID `db:"id" json:"id"`
-	VendorID  uuid.UUID `db:"vendor_id" json:"vendor_id"`
-	Name      string    `db:"name" json:"name"`
-	Price     float64   `db:"price" json:"price"`
-	Img       *string   `db:"img" json:"img,omitempty"` // optional
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
-}
-
-type VendorAdmin struct {
-	UserID   uuid.UUID `db:"user_id"`
-	VendorID uuid.UUID `db:"vendor_id"`
-}
-
-type Table struct {
-	ID             uuid.UUID  `db:"id" json:"id"`
-	VendorID       uuid.UUID  `db:"vendor_id" json:"vendor_id"`
-	Name           string     `db:"name" json:"name"`
-	IsAvailable    bool       `db:"is_available" json:"is_available"`
-	CustomerID     *uuid.UUID `db:"customer_id" json:"customer_id,omitempty"` // Pointer to allow NULL value
-	IsNeedsService bool       `db:"is_needs_service" json:"is_needs_service"`
-}
-
-// OrderStatus defines the possible statuses for an order
-type OrderStatus string
-
-const (
-	Completed OrderStatus = "completed"
-	Preparing OrderStatus = "preparing"
-)
-
-// Order represents the structure of the 'orders' database table
-type Order struct {
-	ID             uuid.UUID   `db:"id" json:"id"`
-	TotalOrderCost float64     `db:"total_order_cost" json:"total_order_cost"`
-	CustomerID     uuid.UUID   `db:"customer_id" json:"customer_id"`
-	VendorID       uuid.UUID   `db:"vendor_id" json:"vendor_id"`
-	Status         OrderStatus `db:"status" json:"status"`
-	CreatedAt      time.Time   `db:"created_at" json:"created_at"`
-	UpdatedAt      time.Time   `db:"updated_at" json:"updated_at"`
-}
-
-type OrderItem struct {
-	ID       uuid.UUID `db:"id" json:"id`
-	OrderID  uuid.UUID `db:"order_id" json:"order_id"`
-	ItemID   uuid.UUID `db:"item_id" json:"item_id`
-	Quantity int       `db:"quantity" json:"quantity"`
-	Price    float64   `db:"price" json:"price"`
-}
-
-// Cart represents a shopping cart in the system
-type Cart struct {
-	ID         uuid.UUID `db:"id" json:"id"`
-	TotalPrice float64   `db:"total_price" json:"total_price"`
-	Quantity   int       `db:"quantity" json:"quantity"`
-	VendorID   uuid.UUID `db:"vendor_id" json:"vendor_id"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
-}
-
-type CartItem struct {
-	CartID   uuid.UUID `db:"cart_id" json:"cart_id"`
-	ItemID   uuid.UUID `db:"item_id" json:"item_id"`
-	Quantity int       `db:"quantity" json:"quantity"`
-}
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type User struct {
+	ID         uuid.UUID `db:"id"        json:"id"`
+	Name       string    `db:"name"      json:"name"`
+	Email      string    `db:"email"     json:"email"`
+	Phone      string    `db:"phone"     json:"phone"`
+	Img        *string   `db:"img"       json:"img"`
+	Password   string    `db:"password"  json:"-"`
+	Created_at time.Time `db:"created_at" json:"created_at"`
+	Updated_at time.Time `db:"updated_at" json:"updated_at"`
+	// Currency prices are also shown in when an exchange rate is known
+	PreferredCurrency *string `db:"preferred_currency" json:"preferred_currency"`
+	// Add roles field to retrieve associated roles
+	Roles []Role `db:"-" json:"roles,omitempty"` // Not stored in 'users' table, but useful for response
+}
+
+type Role struct {
+	ID         int       `db:"id"        json:"id"`
+	Name       string    `db:"name"      json:"name"`
+	Created_at time.Time `db:"created_at" json:"created_at"`
+	Updated_at time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Role names seeded by the roles migration
+const (
+	RoleAdmin    = "admin"
+	RoleVendor   = "vendor"
+	RoleCustomer = "customer"
+)
+
+// HasRole reports whether the user holds at least one of the given roles
+func (u User) HasRole(names ...string) bool {
+	for _, role := range u.Roles {
+		for _, name := range names {
+			if role.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type UserRole struct {
+	UserID uuid.UUID `db:"user_id" json:"user_id"`
+	RoleID int       `db:"role_id" json:"role_id"`
+}
+
+// Response struct for consistent API responses
+type Response struct {
+	Meta interface{} `json:"meta"`
+	Data interface{} `json:"data"`
+}
+
+// ListMeta describes the page returned by an index endpoint
+type ListMeta struct {
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	TotalPages int    `json:"total_pages"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Vendor struct to store vendor information
+type Vendor struct {
+	ID          uuid.UUID `db:"id"        json:"id"`
+	Name        string    `db:"name"      json:"name"`
+	Img         *string   `db:"img"       json:"img"`
+	Description string    `db:"description" json:"description"`
+	Timezone    string    `db:"timezone" json:"timezone"` // IANA name the opening hours are given in
+	Currency    string    `db:"currency" json:"currency"` // ISO 4217 code the vendor's items are priced in
+	// A paused vendor takes no orders until it resumes or PausedUntil passes
+	IsPaused    bool       `db:"is_paused" json:"is_paused"`
+	PausedUntil *time.Time `db:"paused_until" json:"paused_until"`
+	Created_at  time.Time  `db:"created_at" json:"created_at"`
+	Updated_at  time.Time  `db:"updated_at" json:"updated_at"`
+	// Schedule and its evaluation, filled when a single vendor is shown
+	Hours        []VendorHours   `db:"-" json:"hours,omitempty"`
+	Closures     []VendorClosure `db:"-" json:"closures,omitempty"`
+	IsOpenNow    *bool           `db:"-" json:"is_open_now,omitempty"`
+	ClosedReason string          `db:"-" json:"closed_reason,omitempty"`
+}
+
+// VendorHours is one weekly opening window; Weekday follows time.Weekday and times are "HH:MM".
+// A window that closes at or before it opens runs past midnight.
+type VendorHours struct {
+	ID       uuid.UUID    `db:"id" json:"id"`
+	VendorID uuid.UUID    `db:"vendor_id" json:"vendor_id"`
+	Weekday  time.Weekday `db:"weekday" json:"weekday"`
+	OpensAt  string       `db:"opens_at" json:"opens_at"`
+	ClosesAt string       `db:"closes_at" json:"closes_at"`
+}
+
+// VendorClosure is a run of whole days, inclusive, on which the vendor is closed
+type VendorClosure struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	VendorID  uuid.UUID `db:"vendor_id" json:"vendor_id"`
+	StartsOn  time.Time `db:"starts_on" json:"starts_on"`
+	EndsOn    time.Time `db:"ends_on" json:"ends_on"`
+	Reason    *string   `db:"reason" json:"reason"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Item represents an item in the store
+type Item struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	VendorID   uuid.UUID  `db:"vendor_id" json:"vendor_id"`
+	CategoryID *uuid.UUID `db:"category_id" json:"category_id"` // optional menu section
+	Name       string     `db:"name" json:"name"`
+	Price      Money      `db:"price" json:"price"`
+	Currency   string     `db:"currency" json:"currency"` // the vendor's currency
+	Img        *string    `db:"img" json:"img,omitempty"` // optional
+	// IsAvailable is switched off by the vendor or automatically once tracked stock runs out
+	IsAvailable bool      `db:"is_available" json:"is_available"`
+	Stock       *int      `db:"stock" json:"stock"`       // NULL when stock is not tracked
+	SoldOut     bool      `db:"sold_out" json:"sold_out"` // set while IsAvailable is off because stock ran out
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	// Modifier groups, loaded when a single item is shown
+	OptionGroups []OptionGroup `db:"-" json:"option_groups,omitempty"`
+	// Approximate price in the user's preferred currency, when an exchange rate is known
+	DisplayPrice *Money `db:"-" json:"display_price,omitempty"`
+}
+
+// OptionGroup is a set of modifiers for an item, such as sizes or extras.
+// A customer picks between MinSelect and MaxSelect of its options.
+type OptionGroup struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	ItemID    uuid.UUID `db:"item_id" json:"item_id"`
+	Name      string    `db:"name" json:"name"`
+	MinSelect int       `db:"min_select" json:"min_select"`
+	MaxSelect int       `db:"max_select" json:"max_select"`
+	Position  int       `db:"position" json:"position"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	Options   []Option  `db:"-" json:"options,omitempty"`
+}
+
+// Option is one choice in an option group; PriceDelta is added to the item price
+type Option struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	OptionGroupID uuid.UUID `db:"option_group_id" json:"option_group_id"`
+	Name          string    `db:"name" json:"name"`
+	PriceDelta    Money     `db:"price_delta" json:"price_delta"`
+	Currency      string    `db:"currency" json:"-"` // the item's currency
+	Position      int       `db:"position" json:"position"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Category is a menu section of a vendor. Categories nest one level deep through ParentID.
+type Category struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	VendorID  uuid.UUID  `db:"vendor_id" json:"vendor_id"`
+	ParentID  *uuid.UUID `db:"parent_id" json:"parent_id"`
+	Name      string     `db:"name" json:"name"`
+	Position  int        `db:"position" json:"position"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
+	// Filled when the category is returned as part of a menu
+	Subcategories []Category `db:"-" json:"subcategories,omitempty"`
+	Items         []Item     `db:"-" json:"items,omitempty"`
+}
+
+// Menu is a vendor's categories with their items in display order
+type Menu struct {
+	Vendor        Vendor     `json:"vendor"`
+	Categories    []Category `json:"categories"`
+	Uncategorized []Item     `json:"uncategorized"`
+}
+
+// ItemSearchResult is an item matched by a menu search together with its relevance
+type ItemSearchResult struct {
+	Item
+	Rank float64 `db:"rank" json:"rank"`
+}
+
+type VendorAdmin struct {
+	UserID   uuid.UUID `db:"user_id"`
+	VendorID uuid.UUID `db:"vendor_id"`
+}
+
+type Table struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	VendorID       uuid.UUID  `db:"vendor_id" json:"vendor_id"`
+	Name           string     `db:"name" json:"name"`
+	IsAvailable    bool       `db:"is_available" json:"is_available"`
+	CustomerID     *uuid.UUID `db:"customer_id" json:"customer_id,omitempty"` // Pointer to allow NULL value
+	IsNeedsService bool       `db:"is_needs_service" json:"is_needs_service"`
+	Capacity       int        `db:"capacity" json:"capacity"`
+	Zone           string     `db:"zone" json:"zone"`
+	PositionX      int        `db:"position_x" json:"position_x"`
+	PositionY      int        `db:"position_y" json:"position_y"`
+}
+
+// FloorPlan is a vendor's tables grouped by zone with their live occupancy
+type FloorPlan struct {
+	VendorID uuid.UUID   `json:"vendor_id"`
+	Zones    []FloorZone `json:"zones"`
+}
+
+// FloorZone is one area of a floor plan, such as the terrace or the bar
+type FloorZone struct {
+	Name           string       `json:"name"`
+	Seats          int          `json:"seats"`
+	FreeSeats      int          `json:"free_seats"`
+	OccupiedTables int          `json:"occupied_tables"`
+	Tables         []FloorTable `json:"tables"`
+}
+
+// FloorTable is a table on the floor plan
+type FloorTable struct {
+	Table
+	Occupied bool `json:"occupied"`
+}
+
+// TableSession is one seating of a customer at a table, from seat to release
+type TableSession struct {
+	ID           uuid.UUID  `db:"id" json:"id"`
+	TableID      uuid.UUID  `db:"table_id" json:"table_id"`
+	CustomerID   *uuid.UUID `db:"customer_id" json:"customer_id"`
+	SeatedAt     time.Time  `db:"seated_at" json:"seated_at"`
+	ReleasedAt   *time.Time `db:"released_at" json:"released_at"`
+	ServiceCalls int        `db:"service_calls" json:"service_calls"`
+}
+
+// ServiceCall is a seated customer's call for a waiter, open until staff acknowledge it
+type ServiceCall struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	TableID        uuid.UUID  `db:"table_id" json:"table_id"`
+	TableName      string     `db:"table_name" json:"table_name"`
+	Zone           string     `db:"zone" json:"zone"`
+	CustomerID     *uuid.UUID `db:"customer_id" json:"customer_id"`
+	RequestedAt    time.Time  `db:"requested_at" json:"requested_at"`
+	AcknowledgedAt *time.Time `db:"acknowledged_at" json:"acknowledged_at"`
+	AcknowledgedBy *uuid.UUID `db:"acknowledged_by" json:"acknowledged_by"`
+	WaitingSeconds int        `db:"waiting_seconds" json:"waiting_seconds"`
+}
+
+// TableEvent is the data sent on a vendor's table event stream
+type TableEvent struct {
+	Table       Table        `json:"table"`
+	ServiceCall *ServiceCall `json:"service_call,omitempty"`
+}
+
+// OrderEvent is the data sent on the order stream. It stays small because NOTIFY payloads are
+// capped at 8000 bytes; clients load GET /orders/{id} for the rest of the order.
+type OrderEvent struct {
+	ID         uuid.UUID   `json:"id"`
+	VendorID   uuid.UUID   `json:"vendor_id"`
+	CustomerID uuid.UUID   `json:"customer_id"`
+	TableID    *uuid.UUID  `json:"table_id,omitempty"`
+	Status     OrderStatus `json:"status"`
+	Paid       bool        `json:"paid"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// ReservationStatus defines the possible statuses for a table reservation
+type ReservationStatus string
+
+const (
+	ReservationBooked    ReservationStatus = "booked"
+	ReservationCancelled ReservationStatus = "cancelled"
+	ReservationNoShow    ReservationStatus = "no_show"
+)
+
+// Reservation books a table for a party over a period of time
+type Reservation struct {
+	ID         uuid.UUID         `db:"id" json:"id"`
+	TableID    uuid.UUID         `db:"table_id" json:"table_id"`
+	VendorID   uuid.UUID         `db:"vendor_id" json:"vendor_id"` // from the reserved table
+	CustomerID uuid.UUID         `db:"customer_id" json:"customer_id"`
+	PartySize  int               `db:"party_size" json:"party_size"`
+	StartsAt   time.Time         `db:"starts_at" json:"starts_at"`
+	EndsAt     time.Time         `db:"ends_at" json:"ends_at"`
+	Status     ReservationStatus `db:"status" json:"status"`
+	CreatedAt  time.Time         `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time         `db:"updated_at" json:"updated_at"`
+}
+
+// ReservationSlot is a bookable period and the tables still free for all of it
+type ReservationSlot struct {
+	StartsAt time.Time   `json:"starts_at"`
+	EndsAt   time.Time   `json:"ends_at"`
+	TableIDs []uuid.UUID `json:"table_ids"`
+}
+
+// OrderStatus defines the possible statuses for an order
+type OrderStatus string
+
+const (
+	Pending   OrderStatus = "pending"
+	Accepted  OrderStatus = "accepted"
+	Preparing OrderStatus = "preparing"
+	Ready     OrderStatus = "ready"
+	Served    OrderStatus = "served"
+	Completed OrderStatus = "completed"
+	Cancelled OrderStatus = "cancelled"
+	Rejected  OrderStatus = "rejected"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Completed, cancelled and rejected orders are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	Pending:   {Accepted, Rejected, Cancelled},
+	Accepted:  {Preparing, Cancelled},
+	Preparing: {Ready, Cancelled},
+	Ready:     {Served, Completed},
+	Served:    {Completed},
+	Completed: {},
+	Cancelled: {},
+	Rejected:  {},
+}
+
+// IsValid reports whether the status is one of the known order statuses
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order in this status may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderType is how an order reaches the customer
+type OrderType string
+
+const (
+	DineIn   OrderType = "dine_in"
+	Takeaway OrderType = "takeaway"
+	Delivery OrderType = "delivery"
+)
+
+// IsValid reports whether the type is one of the known order types
+func (t OrderType) IsValid() bool {
+	switch t {
+	case DineIn, Takeaway, Delivery:
+		return true
+	}
+	return false
+}
+
+// Order represents the structure of the 'orders' database table
+type Order struct {
+	ID             uuid.UUID   `db:"id" json:"id"`
+	TotalOrderCost Money       `db:"total_order_cost" json:"total_order_cost"`
+	Currency       string      `db:"currency" json:"currency"` // the vendor's currency when the order was placed
+	CustomerID     uuid.UUID   `db:"customer_id" json:"customer_id"`
+	VendorID       uuid.UUID   `db:"vendor_id" json:"vendor_id"`
+	Status         OrderStatus `db:"status" json:"status"`
+	OrderType      OrderType   `db:"order_type" json:"order_type"`
+	TableID        *uuid.UUID  `db:"table_id" json:"table_id,omitempty"` // Set for dine-in orders
+	Prepaid        bool        `db:"prepaid" json:"prepaid"`             // Must be paid before the kitchen accepts it
+	PaidAt         *time.Time  `db:"paid_at" json:"paid_at"`
+	CreatedAt      time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time   `db:"updated_at" json:"updated_at"`
+	// Line items, loaded separately when the order is returned with its items
+	Items []OrderItem `db:"-" json:"items,omitempty"`
+}
+
+// PaymentStatus defines the possible statuses for a payment
+type PaymentStatus string
+
+const (
+	PaymentAuthorized        PaymentStatus = "authorized"
+	PaymentCaptured          PaymentStatus = "captured"
+	PaymentFailed            PaymentStatus = "failed"
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentRefunded          PaymentStatus = "refunded"
+)
+
+// Payment is a charge for an order taken through a payment provider
+type Payment struct {
+	ID             uuid.UUID     `db:"id" json:"id"`
+	OrderID        uuid.UUID     `db:"order_id" json:"order_id"`
+	Provider       string        `db:"provider" json:"provider"`
+	Reference      *string       `db:"reference" json:"reference"`
+	Amount         Money         `db:"amount" json:"amount"`
+	RefundedAmount Money         `db:"refunded_amount" json:"refunded_amount"`
+	Currency       string        `db:"currency" json:"currency"` // the order's currency
+	Status         PaymentStatus `db:"status" json:"status"`
+	FailureReason  *string       `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt      time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+type OrderItem struct {
+	ID       uuid.UUID `db:"id" json:"id"`
+	OrderID  uuid.UUID `db:"order_id" json:"order_id"`
+	ItemID   uuid.UUID `db:"item_id" json:"item_id"`
+	Quantity int       `db:"quantity" json:"quantity"`
+	Price    Money     `db:"price" json:"price"`       // unit price including option price deltas
+	Currency string    `db:"currency" json:"currency"` // the order's currency
+	// Options chosen for the line, loaded with the order
+	Options []OrderItemOption `db:"-" json:"options,omitempty"`
+}
+
+// OrderItemOption is a snapshot of an option chosen on an order line
+type OrderItemOption struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	OrderItemID uuid.UUID  `db:"order_item_id" json:"order_item_id"`
+	OptionID    *uuid.UUID `db:"option_id" json:"option_id"` // NULL once the option is deleted from the menu
+	GroupName   string     `db:"group_name" json:"group_name"`
+	Name        string     `db:"name" json:"name"`
+	PriceDelta  Money      `db:"price_delta" json:"price_delta"`
+}
+
+// Cart represents a shopping cart in the system
+type Cart struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	TotalPrice Money      `db:"total_price" json:"total_price"`
+	Quantity   int        `db:"quantity" json:"quantity"`
+	VendorID   *uuid.UUID `db:"vendor_id" json:"vendor_id"` // vendor of the cart's items, NULL while the cart is empty
+	Currency   *string    `db:"currency" json:"currency"`   // the vendor's currency, NULL with the vendor
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updated_at"`
+	// Lines and vendor summary, only loaded for the full cart view
+	Items  []CartLine `db:"-" json:"items,omitempty"`
+	Vendor *Vendor    `db:"-" json:"vendor,omitempty"`
+}
+
+// CartLine is a cart item joined with the item's current details
+type CartLine struct {
+	ID        uuid.UUID      `db:"id" json:"id"`
+	ItemID    uuid.UUID      `db:"item_id" json:"item_id"`
+	Name      string         `db:"name" json:"name"`
+	Price     Money          `db:"price" json:"price"` // unit price including option price deltas
+	Currency  string         `db:"currency" json:"-"`  // the item's currency
+	Img       *string        `db:"img" json:"img"`
+	Quantity  int            `db:"quantity" json:"quantity"`
+	Subtotal  Money          `db:"subtotal" json:"subtotal"`
+	OptionIDs pq.StringArray `db:"option_ids" json:"-"`
+	Options   []Option       `db:"-" json:"options"`
+}
+
+// CartItem is one cart line: an item with a particular set of options
+type CartItem struct {
+	ID        uuid.UUID      `db:"id" json:"id"`
+	CartID    uuid.UUID      `db:"cart_id" json:"cart_id"`
+	ItemID    uuid.UUID      `db:"item_id" json:"item_id"`
+	Quantity  int            `db:"quantity" json:"quantity"`
+	OptionIDs pq.StringArray `db:"option_ids" json:"option_ids"` // sorted, so equal selections compare equal
+}
+
+// Session is one refresh token in a login family; rotating a token revokes the old row
+type Session struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	UserID     uuid.UUID  `db:"user_id" json:"user_id"`
+	FamilyID   uuid.UUID  `db:"family_id" json:"family_id"`
+	TokenHash  string     `db:"token_hash" json:"-"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	ReplacedBy *uuid.UUID `db:"replaced_by" json:"replaced_by,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
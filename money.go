@@ -0,0 +1,238 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MoneyScale is the number of decimal places money is kept to, matching the DECIMAL(12,3) columns.
+// It covers every currency in currencyExponents.
+const MoneyScale = 3
+
+const moneyFactor = 1000 // 10^MoneyScale
+
+// ErrInvalidMoney is returned for amounts that are not plain decimals with at most MoneyScale places
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Money is an exact amount in thousandths of the currency unit, so sums and products never drift
+// the way float64 does. Currency is an ISO 4217 code, empty while it is not known, such as for an
+// amount read from a query string.
+// Money scans from and is written to DECIMAL columns as text and is sent in JSON as a number
+// with as many decimal places as its currency is quoted to.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// ParseMoney reads a decimal amount such as "12.5" or "-3.25" in currency, rejecting more
+// decimal places than the currency has. An empty currency allows up to MoneyScale places.
+func ParseMoney(s string, currency string) (Money, error) {
+	amount, err := parseMinorUnits(strings.TrimSpace(s))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount}.In(currency)
+}
+
+// In returns the amount in currency, or an error when it has more decimal places than the currency
+func (m Money) In(currency string) (Money, error) {
+	if exponent, ok := CurrencyExponent(currency); ok && !m.fits(exponent) {
+		return Money{}, fmt.Errorf("%w: %s amounts have at most %d decimal places", ErrInvalidMoney, currency, exponent)
+	}
+	m.Currency = currency
+	return m, nil
+}
+
+// MoneyFromMinorUnits converts an amount in the currency's smallest unit, such as cents or yen
+func MoneyFromMinorUnits(units int64, currency string) Money {
+	return Money{Amount: units * pow10(MoneyScale-exponentOf(currency)), Currency: currency}
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for range n {
+		result *= 10
+	}
+	return result
+}
+
+// exponentOf is the currency's decimal places, or two for a currency that is not known
+func exponentOf(currency string) int {
+	if exponent, ok := CurrencyExponent(currency); ok {
+		return exponent
+	}
+	return 2
+}
+
+// fits reports whether the amount can be written with the given number of decimal places
+func (m Money) fits(places int) bool {
+	return m.Amount%pow10(MoneyScale-places) == 0
+}
+
+// MinorUnits returns the amount in the currency's smallest unit, the unit payment providers charge in
+func (m Money) MinorUnits() int64 {
+	return m.Amount / pow10(MoneyScale-exponentOf(m.Currency))
+}
+
+// places is how many decimals String writes: the currency's own, or as many as an amount
+// without a currency needs, but never fewer than two
+func (m Money) places() int {
+	if exponent, ok := CurrencyExponent(m.Currency); ok && m.fits(exponent) {
+		return exponent
+	}
+	if m.fits(2) {
+		return 2
+	}
+	return MoneyScale
+}
+
+// parseMinorUnits converts a decimal string to thousandths. Trailing zeros past MoneyScale,
+// as Postgres pads wider DECIMALs with, are allowed; any other digit past it is rejected.
+func parseMinorUnits(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, ErrInvalidMoney
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > MoneyScale {
+		return 0, ErrInvalidMoney
+	}
+	fraction += strings.Repeat("0", MoneyScale-len(fraction))
+	for _, digits := range []string{whole, fraction} {
+		for _, c := range digits {
+			if c < '0' || c > '9' {
+				return 0, ErrInvalidMoney
+			}
+		}
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// String formats the amount at its currency's precision, e.g. "12.50" for USD or "1200" for JPY
+func (m Money) String() string {
+	return m.format(m.places())
+}
+
+func (m Money) format(places int) string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if places == 0 {
+		return fmt.Sprintf("%s%d", sign, amount/moneyFactor)
+	}
+	fraction := amount % moneyFactor / pow10(MoneyScale-places)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/moneyFactor, places, fraction)
+}
+
+// Add returns m + o. Adding amounts in different currencies is a programming error and panics;
+// callers holding amounts of unknown origin check SameCurrency first.
+func (m Money) Add(o Money) Money {
+	if !m.SameCurrency(o) {
+		panic(fmt.Sprintf("models: adding %s to %s", o.Currency, m.Currency))
+	}
+	if m.Currency == "" {
+		m.Currency = o.Currency
+	}
+	m.Amount += o.Amount
+	return m
+}
+
+// Sub returns m - o, with the same currency rules as Add
+func (m Money) Sub(o Money) Money {
+	o.Amount = -o.Amount
+	return m.Add(o)
+}
+
+// Mul returns m multiplied by a quantity
+func (m Money) Mul(n int) Money {
+	m.Amount *= int64(n)
+	return m
+}
+
+// Convert returns m in another currency at the given rate, rounded half away from zero to the
+// target currency's precision. It is meant for showing approximate prices, never for charging.
+func (m Money) Convert(currency string, rate *big.Rat) Money {
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	step := pow10(MoneyScale - exponentOf(currency))
+	converted.Quo(converted, new(big.Rat).SetInt64(step))
+	// Round the count of minor units by adding a half before truncating toward zero
+	half := big.NewRat(1, 2)
+	if converted.Sign() < 0 {
+		half.Neg(half)
+	}
+	converted.Add(converted, half)
+	units := new(big.Int).Quo(converted.Num(), converted.Denom())
+	return Money{Amount: units.Int64() * step, Currency: currency}
+}
+
+// SameCurrency reports whether m and o can be added. An empty currency matches any other.
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == "" || o.Currency == "" || m.Currency == o.Currency
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+
+// Scan implements sql.Scanner for DECIMAL columns. The currency is left as it was.
+func (m *Money) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		m.Amount, err = parseMinorUnits(string(v))
+	case string:
+		m.Amount, err = parseMinorUnits(v)
+	case int64:
+		m.Amount = v * moneyFactor
+	case nil:
+		m.Amount = 0
+	default:
+		return fmt.Errorf("models: cannot scan %T into Money", src)
+	}
+	return err
+}
+
+// Value implements driver.Valuer, handing Postgres the exact decimal text
+func (m Money) Value() (driver.Value, error) {
+	return m.format(MoneyScale), nil
+}
+
+// MarshalJSON writes the amount as a JSON number at its currency's precision
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts the amount as a JSON number or a string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	s := strings.Trim(string(data), `"`)
+	amount, err := parseMinorUnits(s)
+	if err != nil {
+		return err
+	}
+	m.Amount = amount
+	return nil
+}
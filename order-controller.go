@@ -1,194 +1,385 @@
-package controllers
-
-import (
-	"fmt"
-	"intership/models"
-	"intership/utils"
-	"net/http"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
-)
-
-// Order columns for SQL queries
-var order_columns = []string{
-	"id",
-	"total_order_cost",
-	"customer_id",
-	"vendor_id",
-	"status",
-	"created_at",
-	"updated_at",
-}
-
-// IndexOrderHandler handles GET requests to fetch all orders
-func IndexOrderHandler(w http.ResponseWriter, r *http.Request) {
-	var orders []models.Order
-	query, args, err := QB.Select(strings.Join(order_columns, ", ")).From("orders").ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Select(&orders, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, orders)
-}
-
-// ShowOrderHandler handles GET requests to fetch a single order by ID
-func ShowOrderHandler(w http.ResponseWriter, r *http.Request) {
-	var order models.Order
-	id := r.PathValue("id")
-	query, args, err := QB.Select(strings.Join(order_columns, ", ")).From("orders").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&order, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, order)
-}
-
-// CreateOrderHandler handles POST requests to create a new order
-func CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
-	var order models.Order
-	if r.FormValue("total_order_cost") == "" || r.FormValue("customer_id") == "" || r.FormValue("vendor_id") == "" || r.FormValue("status") == "" {
-		utils.HandelError(w, http.StatusBadRequest, "Total order cost, customer_id, vendor_id, and status are required")
-		return
-	}
-
-	totalOrderCost, err := strconv.ParseFloat(r.FormValue("total_order_cost"), 64)
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid total order cost format")
-		return
-	}
-
-	customerID, err := uuid.Parse(r.FormValue("customer_id")) // Convert string to uuid.UUID
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid customer_id format")
-		return
-	}
-
-	vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
-		return
-	}
-
-	order.ID = uuid.New() // generate new UUID
-	order.TotalOrderCost = totalOrderCost
-	order.CustomerID = customerID
-	order.VendorID = vendorID
-	order.Status = models.OrderStatus(r.FormValue("status")) // Convert status to enum type
-	order.CreatedAt = time.Now()
-	order.UpdatedAt = time.Now()
-
-	query, args, err := QB.Insert("orders").Columns("id", "total_order_cost", "customer_id", "vendor_id", "status", "created_at", "updated_at").
-		Values(order.ID, order.TotalOrderCost, order.CustomerID, order.VendorID, order.Status, order.CreatedAt, order.UpdatedAt).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&order); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error creating order: "+err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusCreated, order)
-}
-
-// UpdateOrderHandler handles PUT requests to update an existing order
-func UpdateOrderHandler(w http.ResponseWriter, r *http.Request) {
-	var order models.Order
-	id := r.PathValue("id")
-
-	query, args, err := QB.Select(strings.Join(order_columns, ", ")).From("orders").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&order, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	// Update fields if provided
-	if r.FormValue("total_order_cost") != "" {
-		totalOrderCost, err := strconv.ParseFloat(r.FormValue("total_order_cost"), 64)
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid total order cost format")
-			return
-		}
-		order.TotalOrderCost = totalOrderCost
-	}
-	if r.FormValue("customer_id") != "" {
-		customerID, err := uuid.Parse(r.FormValue("customer_id")) // Convert string to uuid.UUID
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid customer_id format")
-			return
-		}
-		order.CustomerID = customerID
-	}
-	if r.FormValue("vendor_id") != "" {
-		vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
-			return
-		}
-		order.VendorID = vendorID
-	}
-	if r.FormValue("status") != "" {
-		order.Status = models.OrderStatus(r.FormValue("status")) // Update status as necessary
-	}
-
-	order.UpdatedAt = time.Now()
-
-	query, args, err = QB.Update("orders").
-		Set("total_order_cost", order.TotalOrderCost).
-		Set("customer_id", order.CustomerID).
-		Set("vendor_id", order.VendorID).
-		Set("status", order.Status).
-		Set("updated_at", order.UpdatedAt).
-		Where(squirrel.Eq{"id": order.ID}).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
-		ToSql()
-
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&order); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error updating order: "+err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, order)
-}
-
-// DeleteOrderHandler handles DELETE requests to remove an order
-func DeleteOrderHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	query, args, err := QB.Delete("orders").Where("id=?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error deleting order: "+err.Error())
-		return
-	}
-
-	if _, err := db.Exec(query, args...); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, "Error deleting order: "+err.Error())
-		return
-	}
-
-	utils.SendJSONResponse(w, http.StatusOK, "Order deleted")
-}
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Order columns for SQL queries
+var order_columns = []string{
+	"id",
+	"total_order_cost",
+	"currency",
+	"customer_id",
+	"vendor_id",
+	"status",
+	"order_type",
+	"table_id",
+	"prepaid",
+	"paid_at",
+	"created_at",
+	"updated_at",
+}
+
+var orderListSpec = listSpec{
+	Sortable: map[string]string{
+		"created_at":       "created_at",
+		"updated_at":       "updated_at",
+		"total_order_cost": "total_order_cost",
+		"status":           "status",
+	},
+	Filterable: map[string]string{
+		"vendor_id":   "vendor_id",
+		"customer_id": "customer_id",
+		"status":      "status",
+		"currency":    "currency",
+		"order_type":  "order_type",
+		"table_id":    "table_id",
+		"prepaid":     "prepaid",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  []string{"id"},
+}
+
+// orderTotalSQL adds up an order's lines, whose prices already include their option price deltas
+const orderTotalSQL = "COALESCE((SELECT SUM(order_items.price * order_items.quantity) FROM order_items WHERE order_items.order_id = orders.id), 0)"
+
+// recalculateOrder recomputes an order's total_order_cost from its order_items
+func recalculateOrder(exec sqlx.Execer, orderID uuid.UUID) error {
+	query, args, err := QB.Update("orders").
+		Set("total_order_cost", squirrel.Expr(orderTotalSQL)).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": orderID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = exec.Exec(query, args...)
+	return err
+}
+
+// orderTotal computes what an order's lines add up to, in the order's currency
+func orderTotal(q sqlx.Queryer, orderID uuid.UUID) (models.Money, error) {
+	var total models.Money
+	query, args, err := QB.Select(orderTotalSQL, "currency").From("orders").Where(squirrel.Eq{"id": orderID}).ToSql()
+	if err != nil {
+		return total, err
+	}
+	return total, q.QueryRowx(query, args...).Scan(&total, &total.Currency)
+}
+
+// rejectOrderTotal refuses requests that try to write the computed order total
+func rejectOrderTotal(w http.ResponseWriter, r *http.Request) bool {
+	if r.FormValue("total_order_cost") != "" {
+		utils.HandelError(w, http.StatusBadRequest, "total_order_cost is computed from the order items and cannot be set")
+		return true
+	}
+	return false
+}
+
+// IndexOrderHandler handles GET requests to fetch all orders
+func IndexOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var orders []models.Order
+	selectQuery := QB.Select(strings.Join(order_columns, ", ")).From("orders")
+	visibility, err := orderVisibility(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if visibility != nil {
+		selectQuery = selectQuery.Where(visibility)
+	}
+	meta, ok := paginate(w, r, selectQuery, orderListSpec, &orders)
+	if !ok {
+		return
+	}
+	sendList(w, meta, orders)
+}
+
+// ShowOrderHandler handles GET requests to fetch a single order by ID
+func ShowOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var order models.Order
+	id := r.PathValue("id")
+	query, args, err := QB.Select(strings.Join(order_columns, ", ")).From("orders").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&order, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if allowed, err := canViewOrder(r, order); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if !allowed {
+		utils.HandelError(w, http.StatusForbidden, "You are not allowed to view this order")
+		return
+	}
+	models.ApplyCurrency(&order)
+	utils.SendJSONResponse(w, http.StatusOK, order)
+}
+
+// CreateOrderHandler handles POST requests to create a new order
+func CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var order models.Order
+	if r.FormValue("vendor_id") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "vendor_id is required")
+		return
+	}
+	// The total follows the lines added through order_items
+	if rejectOrderTotal(w, r) {
+		return
+	}
+
+	// Customers order for themselves, only admins may name another customer
+	customerID, ok := actingCustomer(w, r, "customer_id")
+	if !ok {
+		return
+	}
+
+	vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+		return
+	}
+
+	// Orders are placed in the vendor's currency
+	currency, err := vendorCurrency(db, vendorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusBadRequest, "Vendor not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error loading vendor currency: "+err.Error())
+		return
+	}
+	if rejectClosedVendor(w, db, vendorID) {
+		return
+	}
+
+	orderType, tableID, ok := orderPlacement(w, r, db, vendorID, customerID)
+	if !ok {
+		return
+	}
+
+	order.ID = uuid.New() // generate new UUID
+	order.TotalOrderCost = models.Money{Currency: currency}
+	order.Currency = currency
+	order.CustomerID = customerID
+	order.VendorID = vendorID
+	order.OrderType = orderType
+	order.TableID = tableID
+	// Dine-in guests pay at the table, every other order is paid before the kitchen takes it
+	order.Prepaid = orderType != models.DineIn
+	order.Status = models.Pending // every order starts pending and moves on through the status endpoints
+	if r.FormValue("status") != "" && models.OrderStatus(r.FormValue("status")) != models.Pending {
+		utils.HandelError(w, http.StatusBadRequest, "New orders must start in the pending status")
+		return
+	}
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = time.Now()
+
+	query, args, err := QB.Insert("orders").Columns("id", "total_order_cost", "currency", "customer_id", "vendor_id", "status", "order_type", "table_id", "prepaid", "created_at", "updated_at").
+		Values(order.ID, order.TotalOrderCost, order.Currency, order.CustomerID, order.VendorID, order.Status, order.OrderType, order.TableID, order.Prepaid, order.CreatedAt, order.UpdatedAt).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+
+	if err := db.QueryRowx(query, args...).StructScan(&order); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating order: "+err.Error())
+		return
+	}
+	notifyOrderAfterWrite(orderCreatedEvent, order)
+	models.ApplyCurrency(&order)
+	utils.SendJSONResponse(w, http.StatusCreated, order)
+}
+
+// UpdateOrderHandler handles PUT requests to update an existing order
+func UpdateOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	// The status moves through the transition endpoints, which check payment under the row lock
+	if r.FormValue("status") != "" || r.FormValue("customer_id") != "" {
+		utils.HandelError(w, http.StatusBadRequest, "status and customer_id cannot be changed here, use the order status endpoints")
+		return
+	}
+	if rejectOrderTotal(w, r) {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	order, ok := lockOrder(w, tx, id)
+	if !ok {
+		return
+	}
+	if !authorizeVendor(w, r, order.VendorID) {
+		return
+	}
+
+	// Update fields if provided
+	if r.FormValue("vendor_id") != "" {
+		vendorID, err := uuid.Parse(r.FormValue("vendor_id")) // Convert string to uuid.UUID
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid vendor_id format")
+			return
+		}
+		if !authorizeVendor(w, r, vendorID) {
+			return
+		}
+		// The order's amounts stay in the currency it was placed in
+		currency, err := vendorCurrency(tx, vendorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusBadRequest, "Vendor not found")
+			return
+		}
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error loading vendor currency: "+err.Error())
+			return
+		}
+		if currency != order.Currency {
+			utils.HandelError(w, http.StatusConflict, "Order is in "+order.Currency+" and cannot move to a vendor selling in another currency")
+			return
+		}
+		order.VendorID = vendorID
+	}
+	if r.FormValue("order_type") != "" {
+		orderType := models.OrderType(r.FormValue("order_type"))
+		if !orderType.IsValid() {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid order_type, expected dine_in, takeaway or delivery")
+			return
+		}
+		order.OrderType = orderType
+		if orderType != models.DineIn {
+			order.TableID = nil
+		}
+	}
+	if r.FormValue("table_id") != "" {
+		tableID, err := uuid.Parse(r.FormValue("table_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid table_id format")
+			return
+		}
+		order.TableID = &tableID
+	}
+	if order.TableID != nil {
+		if order.OrderType != models.DineIn {
+			utils.HandelError(w, http.StatusBadRequest, "Only dine-in orders can be placed for a table")
+			return
+		}
+		// Checked again when the vendor changes too, a table never serves another vendor's order
+		if !validateOrderTable(w, tx, *order.TableID, order.VendorID) {
+			return
+		}
+	} else if order.OrderType == models.DineIn {
+		utils.HandelError(w, http.StatusBadRequest, "Dine-in orders need a table_id")
+		return
+	}
+
+	order.UpdatedAt = time.Now()
+
+	query, args, err := QB.Update("orders").
+		Set("vendor_id", order.VendorID).
+		Set("order_type", order.OrderType).
+		Set("table_id", order.TableID).
+		Set("updated_at", order.UpdatedAt).
+		Where(squirrel.Eq{"id": order.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
+		ToSql()
+
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+
+	if err := tx.QueryRowx(query, args...).StructScan(&order); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating order: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	notifyOrderAfterWrite(orderUpdatedEvent, order)
+	models.ApplyCurrency(&order)
+	utils.SendJSONResponse(w, http.StatusOK, order)
+}
+
+// DeleteOrderHandler handles DELETE requests to remove an order
+func DeleteOrderHandler(w http.ResponseWriter, r *http.Request) {
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	order, ok := lockOrder(w, tx, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	// Payment records are kept for the books, paying an order locks it too so none can appear meanwhile
+	query, args, err := QB.Select("1").
+		From("payments").
+		Where(squirrel.Eq{"order_id": order.ID}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	var hasPayments bool
+	if err := tx.Get(&hasPayments, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking payments: "+err.Error())
+		return
+	}
+	if hasPayments {
+		utils.HandelError(w, http.StatusConflict, "Order has payments and cannot be deleted, cancel or refund it instead")
+		return
+	}
+	// An order that could still be cancelled has not been served, its items go back on sale
+	if order.Status.CanTransitionTo(models.Cancelled) {
+		if err := releaseOrderStock(tx, order.ID); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+			return
+		}
+	}
+
+	query, args, err = QB.Delete("orders").Where(squirrel.Eq{"id": order.ID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting order: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting order: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	notifyOrderAfterWrite(orderDeletedEvent, order)
+
+	utils.SendJSONResponse(w, http.StatusOK, "Order deleted")
+}
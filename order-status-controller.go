@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// TransitionOrderHandler returns a handler for POST orders/{id}/<action> that moves the order to the given status.
+// Vendor admins may apply any legal transition; customers may only cancel their own pending orders.
+func TransitionOrderHandler(next models.OrderStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		tx, err := db.Beginx()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+			return
+		}
+		defer tx.Rollback()
+
+		var order models.Order
+		query, args, err := QB.Select(strings.Join(order_columns, ", ")).
+			From("orders").
+			Where("id = ?", id).
+			Suffix("FOR UPDATE").
+			ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return
+		}
+		if err := tx.Get(&order, query, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.HandelError(w, http.StatusNotFound, "Order not found")
+				return
+			}
+			utils.HandelError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		manages, err := canManageVendor(r, order.VendorID)
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor access: "+err.Error())
+			return
+		}
+		if !manages {
+			user, _ := CurrentUser(r)
+			if user.ID != order.CustomerID {
+				utils.HandelError(w, http.StatusForbidden, "You are not allowed to change this order")
+				return
+			}
+			if next != models.Cancelled || order.Status != models.Pending {
+				utils.HandelError(w, http.StatusForbidden, "Customers can only cancel pending orders")
+				return
+			}
+		}
+
+		if !order.Status.CanTransitionTo(next) {
+			utils.HandelError(w, http.StatusConflict, fmt.Sprintf("Cannot change order status from %s to %s", order.Status, next))
+			return
+		}
+		if awaitingPayment(order, next) {
+			utils.HandelError(w, http.StatusPaymentRequired, "Order has to be paid before the kitchen takes it")
+			return
+		}
+
+		query, args, err = QB.Update("orders").
+			Set("status", next).
+			Set("updated_at", time.Now()).
+			Where(squirrel.Eq{"id": order.ID}).
+			Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
+			ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return
+		}
+		if err := tx.QueryRowx(query, args...).StructScan(&order); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating order: "+err.Error())
+			return
+		}
+		// Nothing of a cancelled or rejected order is cooked, its items go back on sale
+		if next == models.Cancelled || next == models.Rejected {
+			if err := releaseOrderStock(tx, order.ID); err != nil {
+				utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+				return
+			}
+		}
+		if err := notifyOrder(tx, orderUpdatedEvent, order); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error publishing order event: "+err.Error())
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+			return
+		}
+		models.ApplyCurrency(&order)
+		utils.SendJSONResponse(w, http.StatusOK, order)
+	}
+}
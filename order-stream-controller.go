@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"intership/models"
+	"intership/utils"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Order event types sent on GET /orders/stream
+const (
+	orderCreatedEvent = "order.created"
+	orderUpdatedEvent = "order.updated"
+	orderDeletedEvent = "order.deleted"
+)
+
+// notifyOrder announces an order change to the kitchen of its vendor and to the customer who placed it
+func notifyOrder(exec sqlx.Execer, eventType string, order models.Order) error {
+	event := models.OrderEvent{
+		ID:         order.ID,
+		VendorID:   order.VendorID,
+		CustomerID: order.CustomerID,
+		TableID:    order.TableID,
+		Status:     order.Status,
+		Paid:       order.PaidAt != nil,
+		UpdatedAt:  order.UpdatedAt,
+	}
+	return publishEvent(exec, orderEventsChannel, eventType, order.VendorID, order.CustomerID, event)
+}
+
+// notifyOrderAfterWrite announces a change that is already saved; a failure only costs the live update
+func notifyOrderAfterWrite(eventType string, order models.Order) {
+	if err := notifyOrder(db, eventType, order); err != nil {
+		log.Printf("publishing %s for order %s: %v", eventType, order.ID, err)
+	}
+}
+
+// StreamOrdersHandler handles GET requests for a Server-Sent Events stream of order changes.
+// Vendor staff receive the orders of the vendors they manage, customers their own orders
+// and admins every order. Events carry the order's ids and status; clients fetch the order itself
+// when they need more.
+//
+//	GET /orders/stream
+//	event: order.updated
+//	data: {"id":"...","vendor_id":"...","customer_id":"...","status":"ready","paid":true,"updated_at":"..."}
+func StreamOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	vendorIDs, restricted, err := vendorScope(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor access: "+err.Error())
+		return
+	}
+	managed := map[uuid.UUID]bool{}
+	for _, vendorID := range vendorIDs {
+		managed[vendorID] = true
+	}
+
+	serveEventStream(w, r, orderEventsChannel, func(event streamEvent) bool {
+		return !restricted || event.CustomerID == user.ID || managed[event.VendorID]
+	})
+}
@@ -1,177 +1,413 @@
-package controllers
-
-import (
-	"fmt"
-	"intership/models"
-	"intership/utils"
-	"net/http"
-	"strconv"
-	"strings"
-
-	"github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
-)
-
-var orderItemColumns = []string{
-	"id",
-	"order_id",
-	"item_id",
-	"quantity",
-	"price",
-}
-
-// IndexOrderItemHandler handles GET requests to fetch all order_items
-func IndexOrderItemHandler(w http.ResponseWriter, r *http.Request) {
-	var orderItems []models.OrderItem
-	query, args, err := QB.Select(strings.Join(orderItemColumns, ", ")).From("order_items").ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Select(&orderItems, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, orderItems)
-}
-
-// ShowOrderItemHandler handles GET requests to fetch a single order_item by ID
-func ShowOrderItemHandler(w http.ResponseWriter, r *http.Request) {
-	var orderItem models.OrderItem
-	id := r.PathValue("id")
-	query, args, err := QB.Select(strings.Join(orderItemColumns, ", ")).From("order_items").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&orderItem, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, orderItem)
-}
-
-// CreateOrderItemHandler handles POST requests to create a new order_item
-func CreateOrderItemHandler(w http.ResponseWriter, r *http.Request) {
-	var orderItem models.OrderItem
-	if r.FormValue("order_id") == "" || r.FormValue("item_id") == "" || r.FormValue("quantity") == "" || r.FormValue("price") == "" {
-		utils.HandelError(w, http.StatusBadRequest, "Order ID, Item ID, quantity, and price are required")
-		return
-	}
-
-	orderID, err := uuid.Parse(r.FormValue("order_id"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid order_id format")
-		return
-	}
-
-	itemID, err := uuid.Parse(r.FormValue("item_id"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid item_id format")
-		return
-	}
-
-	quantity, err := strconv.Atoi(r.FormValue("quantity"))
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid quantity format")
-		return
-	}
-
-	price, err := strconv.ParseFloat(r.FormValue("price"), 64)
-	if err != nil {
-		utils.HandelError(w, http.StatusBadRequest, "Invalid price format")
-		return
-	}
-
-	orderItem.ID = uuid.New()
-	orderItem.OrderID = orderID
-	orderItem.ItemID = itemID
-	orderItem.Quantity = quantity
-	orderItem.Price = price
-
-	query, args, err := QB.Insert("order_items").
-		Columns("id", "order_id", "item_id", "quantity", "price").
-		Values(orderItem.ID, orderItem.OrderID, orderItem.ItemID, orderItem.Quantity, orderItem.Price).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(orderItemColumns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&orderItem); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusCreated, orderItem)
-}
-
-// UpdateOrderItemHandler handles PUT requests to update an existing order_item
-func UpdateOrderItemHandler(w http.ResponseWriter, r *http.Request) {
-	var orderItem models.OrderItem
-	id := r.PathValue("id")
-
-	query, args, err := QB.Select(strings.Join(orderItemColumns, ", ")).From("order_items").Where("id = ?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	err = db.Get(&orderItem, query, args...)
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if r.FormValue("quantity") != "" {
-		quantity, err := strconv.Atoi(r.FormValue("quantity"))
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid quantity format")
-			return
-		}
-		orderItem.Quantity = quantity
-	}
-	if r.FormValue("price") != "" {
-		price, err := strconv.ParseFloat(r.FormValue("price"), 64)
-		if err != nil {
-			utils.HandelError(w, http.StatusBadRequest, "Invalid price format")
-			return
-		}
-		orderItem.Price = price
-	}
-
-	query, args, err = QB.Update("order_items").
-		Set("quantity", orderItem.Quantity).
-		Set("price", orderItem.Price).
-		Where(squirrel.Eq{"id": orderItem.ID}).
-		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(orderItemColumns, ", "))).
-		ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err := db.QueryRowx(query, args...).StructScan(&orderItem); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	utils.SendJSONResponse(w, http.StatusOK, orderItem)
-}
-
-// DeleteOrderItemHandler handles DELETE requests to remove an order_item
-func DeleteOrderItemHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	query, args, err := QB.Delete("order_items").Where("id=?", id).ToSql()
-	if err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if _, err := db.Exec(query, args...); err != nil {
-		utils.HandelError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	utils.SendJSONResponse(w, http.StatusOK, "Order item deleted")
-}
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var orderItemColumns = []string{
+	"id",
+	"order_id",
+	"item_id",
+	"quantity",
+	"price",
+	// Lines are priced in their order's currency
+	"(SELECT currency FROM orders WHERE orders.id = order_items.order_id) AS currency",
+}
+
+var orderItemListSpec = listSpec{
+	Sortable:   map[string]string{"quantity": "quantity", "price": "price"},
+	Filterable: map[string]string{"order_id": "order_id", "item_id": "item_id"},
+	TieBreaker: []string{"order_id", "id"},
+}
+
+// orderItemVendorLookup selects the vendor that received the order an order_item belongs to
+func orderItemVendorLookup(id string) squirrel.SelectBuilder {
+	return QB.Select("orders.vendor_id").
+		From("order_items").
+		Join("orders ON orders.id = order_items.order_id").
+		Where("order_items.id = ?", id)
+}
+
+// IndexOrderItemHandler handles GET requests to fetch all order_items
+func IndexOrderItemHandler(w http.ResponseWriter, r *http.Request) {
+	var orderItems []models.OrderItem
+	selectQuery := QB.Select(strings.Join(orderItemColumns, ", ")).From("order_items")
+	visibility, err := orderVisibility(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if visibility != nil {
+		selectQuery = selectQuery.Where(squirrel.Expr("order_id IN (?)", squirrel.Select("orders.id").From("orders").Where(visibility)))
+	}
+	meta, ok := paginate(w, r, selectQuery, orderItemListSpec, &orderItems)
+	if !ok {
+		return
+	}
+	sendList(w, meta, orderItems)
+}
+
+// ShowOrderItemHandler handles GET requests to fetch a single order_item by ID
+func ShowOrderItemHandler(w http.ResponseWriter, r *http.Request) {
+	var orderItem models.OrderItem
+	id := r.PathValue("id")
+	query, args, err := QB.Select(strings.Join(orderItemColumns, ", ")).From("order_items").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = db.Get(&orderItem, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !authorizeOrderAccess(w, r, orderItem.OrderID) {
+		return
+	}
+	items := []models.OrderItem{orderItem}
+	if err := loadOrderItemOptions(db, items); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	models.ApplyCurrency(&items[0])
+	utils.SendJSONResponse(w, http.StatusOK, items[0])
+}
+
+// lockEditableOrder locks an order whose lines are about to change. Lines only change while the
+// order is pending and unpaid, afterwards the kitchen or the customer's payment relies on them.
+func lockEditableOrder(w http.ResponseWriter, tx *sqlx.Tx, orderID string) (models.Order, bool) {
+	order, ok := lockOrder(w, tx, orderID)
+	if !ok {
+		return order, false
+	}
+	if order.Status != models.Pending || order.PaidAt != nil {
+		utils.HandelError(w, http.StatusConflict, "Order lines can only change while the order is pending and unpaid")
+		return order, false
+	}
+	return order, true
+}
+
+// lockOrderOfItem locks the order an order_item belongs to, see lockEditableOrder
+func lockOrderOfItem(w http.ResponseWriter, tx *sqlx.Tx, id string) (models.Order, bool) {
+	var orderID uuid.UUID
+	query, args, err := QB.Select("order_id").From("order_items").Where("id = ?", id).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return models.Order{}, false
+	}
+	if err := tx.Get(&orderID, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Order item not found")
+			return models.Order{}, false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return models.Order{}, false
+	}
+	return lockEditableOrder(w, tx, orderID.String())
+}
+
+// CreateOrderItemHandler handles POST requests to add a line to a pending order.
+// The line is priced from the item and its chosen options, as at checkout.
+func CreateOrderItemHandler(w http.ResponseWriter, r *http.Request) {
+	var orderItem models.OrderItem
+	if r.FormValue("order_id") == "" || r.FormValue("item_id") == "" || r.FormValue("quantity") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "Order ID, Item ID and quantity are required")
+		return
+	}
+	if r.FormValue("price") != "" {
+		utils.HandelError(w, http.StatusBadRequest, "price is taken from the item and its options and cannot be set")
+		return
+	}
+
+	orderID, err := uuid.Parse(r.FormValue("order_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid order_id format")
+		return
+	}
+	if !authorizeOrderAccess(w, r, orderID) {
+		return
+	}
+
+	itemID, err := uuid.Parse(r.FormValue("item_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid item_id format")
+		return
+	}
+
+	optionIDs, err := optionIDsFromForm(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quantity, err := strconv.Atoi(r.FormValue("quantity"))
+	if err != nil || quantity < 1 {
+		utils.HandelError(w, http.StatusBadRequest, "Quantity must be a whole number of at least 1")
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	order, ok := lockEditableOrder(w, tx, orderID.String())
+	if !ok {
+		return
+	}
+
+	var item struct {
+		VendorID uuid.UUID    `db:"vendor_id"`
+		Price    models.Money `db:"price"`
+		Currency string       `db:"currency"`
+	}
+	query, args, err := QB.Select("vendor_id", "price", "currency").From("items").Where(squirrel.Eq{"id": itemID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Get(&item, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Item not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if item.VendorID != order.VendorID {
+		utils.HandelError(w, http.StatusConflict, "Item is sold by another vendor than the order's")
+		return
+	}
+	// An order is settled in one currency, so the item must be sold in the order's
+	if item.Currency != order.Currency {
+		utils.HandelError(w, http.StatusConflict, "Item is priced in "+item.Currency+" but the order is in "+order.Currency)
+		return
+	}
+
+	options, err := resolveOptions(tx, itemID, optionIDs)
+	if err != nil {
+		var selectionErr optionSelectionError
+		if errors.As(err, &selectionErr) {
+			utils.HandelError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking item options: "+err.Error())
+		return
+	}
+
+	orderItem.ID = uuid.New()
+	orderItem.OrderID = orderID
+	orderItem.ItemID = itemID
+	orderItem.Quantity = quantity
+	orderItem.Price = item.Price
+	orderItem.Price.Currency = order.Currency
+	for _, option := range options {
+		orderItem.Price = orderItem.Price.Add(option.PriceDelta)
+	}
+
+	if err := reserveStock(tx, map[uuid.UUID]int{orderItem.ItemID: orderItem.Quantity}); err != nil {
+		var unavailable itemUnavailableError
+		if errors.As(err, &unavailable) {
+			utils.HandelError(w, http.StatusConflict, err.Error())
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+		return
+	}
+
+	query, args, err = QB.Insert("order_items").
+		Columns("id", "order_id", "item_id", "quantity", "price").
+		Values(orderItem.ID, orderItem.OrderID, orderItem.ItemID, orderItem.Quantity, orderItem.Price).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(orderItemColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.QueryRowx(query, args...).StructScan(&orderItem); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(options) > 0 {
+		// Snapshot the chosen options like checkout does
+		optionInsert := QB.Insert("order_item_options").Columns("order_item_id", "option_id", "group_name", "name", "price_delta")
+		for _, option := range options {
+			optionInsert = optionInsert.Values(orderItem.ID, option.ID, option.GroupName, option.Name, option.PriceDelta)
+		}
+		query, args, err = optionInsert.ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error creating order item options: "+err.Error())
+			return
+		}
+	}
+	if err := recalculateOrder(tx, order.ID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating order total: "+err.Error())
+		return
+	}
+	items := []models.OrderItem{orderItem}
+	if err := loadOrderItemOptions(tx, items); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&items[0])
+	utils.SendJSONResponse(w, http.StatusCreated, items[0])
+}
+
+// UpdateOrderItemHandler handles PUT requests to update an existing order_item
+func UpdateOrderItemHandler(w http.ResponseWriter, r *http.Request) {
+	var orderItem models.OrderItem
+	id := r.PathValue("id")
+
+	if !authorizeVendorOf(w, r, orderItemVendorLookup(id)) {
+		return
+	}
+	if r.FormValue("price") != "" {
+		utils.HandelError(w, http.StatusBadRequest, "price is taken from the item and its options and cannot be set")
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	if _, ok := lockOrderOfItem(w, tx, id); !ok {
+		return
+	}
+
+	// The line stays locked while its stock is adjusted so concurrent updates see the same quantity
+	query, args, err := QB.Select(strings.Join(orderItemColumns, ", ")).From("order_items").Where("id = ?", id).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = tx.Get(&orderItem, query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.FormValue("quantity") != "" {
+		quantity, err := strconv.Atoi(r.FormValue("quantity"))
+		if err != nil || quantity < 1 {
+			utils.HandelError(w, http.StatusBadRequest, "Quantity must be a whole number of at least 1")
+			return
+		}
+		// Take extra units out of stock, or give back the ones no longer ordered
+		if quantity > orderItem.Quantity {
+			if err := reserveStock(tx, map[uuid.UUID]int{orderItem.ItemID: quantity - orderItem.Quantity}); err != nil {
+				var unavailable itemUnavailableError
+				if errors.As(err, &unavailable) {
+					utils.HandelError(w, http.StatusConflict, err.Error())
+					return
+				}
+				utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+				return
+			}
+		} else if quantity < orderItem.Quantity {
+			if err := releaseStock(tx, map[uuid.UUID]int{orderItem.ItemID: orderItem.Quantity - quantity}); err != nil {
+				utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+				return
+			}
+		}
+		orderItem.Quantity = quantity
+	}
+
+	query, args, err = QB.Update("order_items").
+		Set("quantity", orderItem.Quantity).
+		Where(squirrel.Eq{"id": orderItem.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(orderItemColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.QueryRowx(query, args...).StructScan(&orderItem); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := recalculateOrder(tx, orderItem.OrderID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating order total: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&orderItem)
+	utils.SendJSONResponse(w, http.StatusOK, orderItem)
+}
+
+// DeleteOrderItemHandler handles DELETE requests to remove an order_item
+func DeleteOrderItemHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, orderItemVendorLookup(id)) {
+		return
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	order, ok := lockOrderOfItem(w, tx, id)
+	if !ok {
+		return
+	}
+
+	var removed models.OrderItem
+	query, args, err := QB.Delete("order_items").Where("id=?", id).Suffix("RETURNING item_id, quantity").ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Get(&removed, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Order item not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// The removed units go back into stock
+	if err := releaseStock(tx, map[uuid.UUID]int{removed.ItemID: removed.Quantity}); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating stock: "+err.Error())
+		return
+	}
+	if err := recalculateOrder(tx, order.ID); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating order total: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, "Order item deleted")
+}
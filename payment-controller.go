@@ -0,0 +1,438 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/payments"
+	"intership/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var paymentProvider payments.Provider
+
+// SetPaymentProvider sets the provider orders are charged through
+func SetPaymentProvider(provider payments.Provider) {
+	paymentProvider = provider
+}
+
+var paymentColumns = []string{
+	"id",
+	"order_id",
+	"provider",
+	"reference",
+	"amount",
+	"refunded_amount",
+	"status",
+	"failure_reason",
+	"created_at",
+	"updated_at",
+	// Payments are taken in their order's currency
+	"(SELECT currency FROM orders WHERE orders.id = payments.order_id) AS currency",
+}
+
+// awaitingPayment reports whether a prepaid order has to be paid before moving to next.
+// Unpaid orders can still be cancelled or rejected.
+func awaitingPayment(order models.Order, next models.OrderStatus) bool {
+	return order.Prepaid && order.PaidAt == nil && next != models.Cancelled && next != models.Rejected && next != models.Pending
+}
+
+// lockOrder loads an order for update inside tx
+func lockOrder(w http.ResponseWriter, tx *sqlx.Tx, id string) (models.Order, bool) {
+	var order models.Order
+	query, args, err := QB.Select(strings.Join(order_columns, ", ")).
+		From("orders").
+		Where("id = ?", id).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return order, false
+	}
+	if err := tx.Get(&order, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Order not found")
+			return order, false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return order, false
+	}
+	return order, true
+}
+
+// insertPayment records a payment attempt
+func insertPayment(tx *sqlx.Tx, payment *models.Payment) error {
+	query, args, err := QB.Insert("payments").
+		Columns("id", "order_id", "provider", "reference", "amount", "status", "failure_reason").
+		Values(payment.ID, payment.OrderID, payment.Provider, payment.Reference, payment.Amount, payment.Status, payment.FailureReason).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(paymentColumns, ", "))).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	return tx.QueryRowx(query, args...).StructScan(payment)
+}
+
+// setOrderPaid sets or clears the order's paid_at and announces the change
+func setOrderPaid(tx *sqlx.Tx, orderID uuid.UUID, paidAt *time.Time) error {
+	var order models.Order
+	query, args, err := QB.Update("orders").
+		Set("paid_at", paidAt).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": orderID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(order_columns, ", "))).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRowx(query, args...).StructScan(&order); err != nil {
+		return err
+	}
+	return notifyOrder(tx, orderUpdatedEvent, order)
+}
+
+// IndexOrderPaymentsHandler handles GET requests for the payment attempts of an order
+func IndexOrderPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid order id format")
+		return
+	}
+	if !authorizeOrderAccess(w, r, orderID) {
+		return
+	}
+	paymentList := []models.Payment{}
+	query, args, err := QB.Select(paymentColumns...).
+		From("payments").
+		Where(squirrel.Eq{"order_id": orderID}).
+		OrderBy("created_at", "id").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Select(&paymentList, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	models.ApplyCurrency(paymentList)
+	utils.SendJSONResponse(w, http.StatusOK, paymentList)
+}
+
+// PayOrderHandler handles POST requests from the customer paying an order in full.
+// The token is the payment method collected by the frontend from the provider.
+//
+//	POST /orders/{id}/pay  token=<payment method token>
+func PayOrderHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	if r.FormValue("token") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// The order stays locked while the provider is called so the same order is not charged twice
+	order, ok := lockOrder(w, tx, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if order.CustomerID != user.ID {
+		utils.HandelError(w, http.StatusForbidden, "Only the customer who placed this order can pay for it")
+		return
+	}
+	if order.Status == models.Cancelled || order.Status == models.Rejected {
+		utils.HandelError(w, http.StatusConflict, fmt.Sprintf("Order is %s", order.Status))
+		return
+	}
+	if order.PaidAt != nil {
+		utils.HandelError(w, http.StatusConflict, "Order is already paid")
+		return
+	}
+	// The charge is what the order's lines add up to, never a total a client sent.
+	// Lines cannot change while the order is locked here, nor once it is paid.
+	total, err := orderTotal(tx, order.ID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error computing order total: "+err.Error())
+		return
+	}
+	// Providers charge in the currency's minor units, such as cents
+	amount := total.MinorUnits()
+	if amount <= 0 {
+		utils.HandelError(w, http.StatusConflict, "Order has nothing to pay")
+		return
+	}
+
+	payment := models.Payment{
+		ID:       uuid.New(),
+		OrderID:  order.ID,
+		Provider: paymentProvider.Name(),
+		Amount:   total,
+		Status:   models.PaymentCaptured,
+	}
+	reference, chargeErr := paymentProvider.Authorize(r.Context(), payments.AuthorizeRequest{OrderID: order.ID, Amount: amount, Currency: order.Currency, Token: r.FormValue("token")})
+	if chargeErr == nil {
+		payment.Reference = &reference
+		chargeErr = paymentProvider.Capture(r.Context(), reference, amount)
+	}
+	if chargeErr != nil {
+		// Keep a record of the failed attempt before answering
+		reason := chargeErr.Error()
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = &reason
+		if err := insertPayment(tx, &payment); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error recording payment: "+err.Error())
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+			return
+		}
+		if errors.Is(chargeErr, payments.ErrDeclined) {
+			utils.HandelError(w, http.StatusPaymentRequired, "Payment declined")
+			return
+		}
+		utils.HandelError(w, http.StatusBadGateway, "Payment provider error: "+reason)
+		return
+	}
+
+	now := time.Now()
+	if err := insertPayment(tx, &payment); err != nil {
+		refundUnrecorded(r, reference, amount)
+		utils.HandelError(w, http.StatusInternalServerError, "Error recording payment: "+err.Error())
+		return
+	}
+	if err := setOrderPaid(tx, order.ID, &now); err != nil {
+		refundUnrecorded(r, reference, amount)
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating order: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		refundUnrecorded(r, reference, amount)
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&payment)
+	utils.SendJSONResponse(w, http.StatusCreated, payment)
+}
+
+// refundUnrecorded gives back a charge that could not be saved, so the customer is not billed for nothing
+func refundUnrecorded(r *http.Request, reference string, amount int64) {
+	paymentProvider.Refund(r.Context(), reference, amount)
+}
+
+// RefundOrderHandler handles POST requests from vendor staff refunding an order's payment,
+// in full or the given amount
+//
+//	POST /orders/{id}/refund  amount=4.50
+func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	order, ok := lockOrder(w, tx, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if !authorizeVendor(w, r, order.VendorID) {
+		return
+	}
+
+	var payment models.Payment
+	query, args, err := QB.Select(paymentColumns...).
+		From("payments").
+		Where(squirrel.Eq{"order_id": order.ID, "status": []models.PaymentStatus{models.PaymentCaptured, models.PaymentPartiallyRefunded}}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Get(&payment, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusConflict, "Order has no payment to refund")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if payment.Provider != paymentProvider.Name() {
+		utils.HandelError(w, http.StatusConflict, fmt.Sprintf("Payment was taken through %s, which is not configured", payment.Provider))
+		return
+	}
+
+	models.ApplyCurrency(&payment)
+	remaining := payment.Amount.Sub(payment.RefundedAmount)
+	amount := remaining
+	if r.FormValue("amount") != "" {
+		value, err := models.ParseMoney(r.FormValue("amount"), payment.Currency)
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid amount: "+err.Error())
+			return
+		}
+		amount = value
+	}
+	if !amount.IsPositive() || amount.Amount > remaining.Amount {
+		smallest := models.MoneyFromMinorUnits(1, payment.Currency)
+		utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("amount must be between %s and %s", smallest, remaining))
+		return
+	}
+
+	if err := paymentProvider.Refund(r.Context(), *payment.Reference, amount.MinorUnits()); err != nil {
+		utils.HandelError(w, http.StatusBadGateway, "Payment provider error: "+err.Error())
+		return
+	}
+
+	status := models.PaymentPartiallyRefunded
+	if amount == remaining {
+		status = models.PaymentRefunded
+	}
+	query, args, err = QB.Update("payments").
+		Set("refunded_amount", payment.RefundedAmount.Add(amount)).
+		Set("status", status).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": payment.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(paymentColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.QueryRowx(query, args...).StructScan(&payment); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating payment: "+err.Error())
+		return
+	}
+	// A fully refunded order counts as unpaid again
+	if status == models.PaymentRefunded {
+		if err := setOrderPaid(tx, order.ID, nil); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating order: "+err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	models.ApplyCurrency(&payment)
+	utils.SendJSONResponse(w, http.StatusOK, payment)
+}
+
+// refundReported reads a refund webhook for payment. The event amount is the total refunded so far
+// in minor units, so a replayed or out-of-order event that reports no more than is already recorded
+// changes nothing and ok is false.
+func refundReported(payment models.Payment, amount int64) (models.Money, models.PaymentStatus, bool) {
+	refunded := models.MoneyFromMinorUnits(min(amount, payment.Amount.MinorUnits()), payment.Currency)
+	if refunded.Amount <= payment.RefundedAmount.Amount {
+		return payment.RefundedAmount, payment.Status, false
+	}
+	if refunded.Amount == payment.Amount.Amount {
+		return refunded, models.PaymentRefunded, true
+	}
+	return refunded, models.PaymentPartiallyRefunded, true
+}
+
+// PaymentWebhookHandler handles POST requests from the payment provider reporting payments
+// that settle after the request that started them
+func PaymentWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	event, err := paymentProvider.VerifyWebhook(r)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			utils.HandelError(w, http.StatusUnauthorized, "Invalid webhook signature")
+			return
+		}
+		utils.HandelError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	var payment models.Payment
+	query, args, err := QB.Select(paymentColumns...).
+		From("payments").
+		Where(squirrel.Eq{"provider": paymentProvider.Name(), "reference": event.Reference}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Get(&payment, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Payment not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Amounts in the event are minor units of the payment's currency
+	models.ApplyCurrency(&payment)
+
+	update := QB.Update("payments").Set("updated_at", time.Now()).Where(squirrel.Eq{"id": payment.ID})
+	var paidAt *time.Time
+	settled := false
+	switch event.Type {
+	case payments.EventCaptured:
+		if payment.Status != models.PaymentAuthorized {
+			break // already settled, providers may deliver an event more than once
+		}
+		now := time.Now()
+		paidAt = &now
+		settled = true
+		update = update.Set("status", models.PaymentCaptured)
+	case payments.EventFailed:
+		if payment.Status != models.PaymentAuthorized {
+			break
+		}
+		update = update.Set("status", models.PaymentFailed).Set("failure_reason", "Reported failed by the provider")
+	case payments.EventRefunded:
+		refunded, status, ok := refundReported(payment, event.Amount)
+		if !ok {
+			break
+		}
+		settled = status == models.PaymentRefunded
+		update = update.Set("refunded_amount", refunded).Set("status", status)
+	default:
+		utils.SendJSONResponse(w, http.StatusOK, "Event ignored")
+		return
+	}
+
+	query, args, err = update.ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating payment: "+err.Error())
+		return
+	}
+	if settled {
+		if err := setOrderPaid(tx, payment.OrderID, paidAt); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating order: "+err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, "Event processed")
+}
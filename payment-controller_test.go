@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"intership/models"
+	"testing"
+)
+
+func TestRefundReported(t *testing.T) {
+	payment := func(currency string, amount, refunded int64) models.Payment {
+		p := models.Payment{
+			Amount:         models.MoneyFromMinorUnits(amount, currency),
+			RefundedAmount: models.MoneyFromMinorUnits(refunded, currency),
+			Currency:       currency,
+			Status:         models.PaymentCaptured,
+		}
+		if refunded > 0 {
+			p.Status = models.PaymentPartiallyRefunded
+		}
+		return p
+	}
+	cases := []struct {
+		name       string
+		payment    models.Payment
+		event      int64
+		ok         bool
+		refunded   string
+		wantStatus models.PaymentStatus
+	}{
+		{"partial", payment("USD", 1000, 0), 400, true, "4.00", models.PaymentPartiallyRefunded},
+		{"rest", payment("USD", 1000, 400), 1000, true, "10.00", models.PaymentRefunded},
+		{"replayed", payment("USD", 1000, 400), 400, false, "4.00", models.PaymentPartiallyRefunded},
+		{"out of order", payment("USD", 1000, 400), 300, false, "4.00", models.PaymentPartiallyRefunded},
+		{"more than paid", payment("USD", 1000, 0), 5000, true, "10.00", models.PaymentRefunded},
+		{"yen have no minor unit", payment("JPY", 1200, 0), 1200, true, "1200", models.PaymentRefunded},
+		{"dinar thousandths", payment("KWD", 2500, 0), 1250, true, "1.250", models.PaymentPartiallyRefunded},
+	}
+	for _, c := range cases {
+		refunded, status, ok := refundReported(c.payment, c.event)
+		if ok != c.ok || refunded.String() != c.refunded || status != c.wantStatus {
+			t.Errorf("%s: got %s %s %v, want %s %s %v", c.name, refunded, status, ok, c.refunded, c.wantStatus, c.ok)
+		}
+	}
+}
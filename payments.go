@@ -0,0 +1,156 @@
+// Package payments defines the interface the API charges orders through and a fake provider
+// for tests and local development. Amounts are in the currency's minor units, such as cents or yen.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrDeclined is returned when the customer's payment method refuses the charge
+	ErrDeclined = errors.New("payments: payment declined")
+	// ErrInvalidSignature is returned for webhooks that were not sent by the provider
+	ErrInvalidSignature = errors.New("payments: invalid webhook signature")
+)
+
+// Webhook event types reported by providers
+const (
+	EventCaptured = "payment.captured"
+	EventFailed   = "payment.failed"
+	EventRefunded = "payment.refunded"
+)
+
+// AuthorizeRequest asks the provider to hold an amount on the customer's payment method
+type AuthorizeRequest struct {
+	OrderID uuid.UUID
+	Amount  int64
+	// Currency is the ISO 4217 code Amount is in
+	Currency string
+	// Token identifies the payment method collected by the frontend
+	Token string
+}
+
+// WebhookEvent is a payment update sent by the provider
+type WebhookEvent struct {
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+	Amount    int64  `json:"amount"`
+}
+
+// Provider is a payment service. Reference is the provider's id for an authorized payment.
+type Provider interface {
+	// Name is stored with each payment so it is settled through the provider that took it
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (reference string, err error)
+	Capture(ctx context.Context, reference string, amount int64) error
+	Refund(ctx context.Context, reference string, amount int64) error
+	// VerifyWebhook checks the request was signed by the provider and returns the event it carries
+	VerifyWebhook(r *http.Request) (WebhookEvent, error)
+}
+
+// FakeDeclineToken makes the fake provider decline the payment; any other token is approved
+const FakeDeclineToken = "tok_decline"
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake webhook body
+const FakeSignatureHeader = "X-Fake-Signature"
+
+type fakePayment struct {
+	authorized, captured, refunded int64
+}
+
+// Fake is an in-memory provider. Its payments only live as long as the process.
+type Fake struct {
+	secret   []byte
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+// NewFake returns a fake provider whose webhooks are signed with secret
+func NewFake(secret string) *Fake {
+	return &Fake{secret: []byte(secret), payments: map[string]*fakePayment{}}
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	if req.Amount <= 0 {
+		return "", fmt.Errorf("payments: invalid amount %d", req.Amount)
+	}
+	if req.Token == FakeDeclineToken {
+		return "", ErrDeclined
+	}
+	reference := "fake_" + uuid.NewString()
+	f.mu.Lock()
+	f.payments[reference] = &fakePayment{authorized: req.Amount}
+	f.mu.Unlock()
+	return reference, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, reference string, amount int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[reference]
+	if !ok {
+		return fmt.Errorf("payments: unknown payment %s", reference)
+	}
+	if amount <= 0 || payment.captured+amount > payment.authorized {
+		return fmt.Errorf("payments: cannot capture %d of %s", amount, reference)
+	}
+	payment.captured += amount
+	return nil
+}
+
+func (f *Fake) Refund(ctx context.Context, reference string, amount int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[reference]
+	if !ok {
+		return fmt.Errorf("payments: unknown payment %s", reference)
+	}
+	if amount <= 0 || payment.refunded+amount > payment.captured {
+		return fmt.Errorf("payments: cannot refund %d of %s", amount, reference)
+	}
+	payment.refunded += amount
+	return nil
+}
+
+func (f *Fake) mac(body []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Sign returns the signature header value for a webhook body, for simulating provider callbacks
+func (f *Fake) Sign(body []byte) string {
+	return hex.EncodeToString(f.mac(body))
+}
+
+func (f *Fake) VerifyWebhook(r *http.Request) (WebhookEvent, error) {
+	var event WebhookEvent
+	if len(f.secret) == 0 {
+		return event, errors.New("payments: webhook secret is not configured")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return event, err
+	}
+	signature, err := hex.DecodeString(r.Header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.mac(body)) {
+		return event, ErrInvalidSignature
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return event, fmt.Errorf("payments: invalid webhook body: %w", err)
+	}
+	return event, nil
+}
@@ -0,0 +1,126 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func authorize(t *testing.T, f *Fake, amount int64) string {
+	t.Helper()
+	reference, err := f.Authorize(context.Background(), AuthorizeRequest{OrderID: uuid.New(), Amount: amount, Currency: "USD", Token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return reference
+}
+
+func TestFakeChargesAndDeclines(t *testing.T) {
+	f := NewFake("secret")
+	ctx := context.Background()
+
+	reference := authorize(t, f, 1250)
+	if err := f.Capture(ctx, reference, 1250); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	// Capturing again would charge the customer twice
+	if err := f.Capture(ctx, reference, 1); err == nil {
+		t.Error("second capture succeeded, want error")
+	}
+	if err := f.Capture(ctx, "fake_unknown", 1); err == nil {
+		t.Error("capture of unknown payment succeeded, want error")
+	}
+
+	_, err := f.Authorize(ctx, AuthorizeRequest{OrderID: uuid.New(), Amount: 1250, Token: FakeDeclineToken})
+	if !errors.Is(err, ErrDeclined) {
+		t.Errorf("Authorize with %s: err = %v, want ErrDeclined", FakeDeclineToken, err)
+	}
+	for _, amount := range []int64{0, -100} {
+		if _, err := f.Authorize(ctx, AuthorizeRequest{OrderID: uuid.New(), Amount: amount, Token: "tok_visa"}); err == nil {
+			t.Errorf("Authorize of %d succeeded, want error", amount)
+		}
+	}
+}
+
+func TestFakeRefundBounds(t *testing.T) {
+	f := NewFake("secret")
+	ctx := context.Background()
+
+	uncaptured := authorize(t, f, 1000)
+	if err := f.Refund(ctx, uncaptured, 100); err == nil {
+		t.Error("refund of an uncaptured payment succeeded, want error")
+	}
+
+	reference := authorize(t, f, 1000)
+	if err := f.Capture(ctx, reference, 1000); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	steps := []struct {
+		amount int64
+		ok     bool
+	}{
+		{0, false},
+		{-1, false},
+		{1001, false},
+		{400, true},
+		{601, false}, // more than is left
+		{600, true},
+		{1, false}, // fully refunded, a replay must not refund again
+	}
+	for _, step := range steps {
+		err := f.Refund(ctx, reference, step.amount)
+		if (err == nil) != step.ok {
+			t.Errorf("Refund(%d): err = %v, want ok = %v", step.amount, err, step.ok)
+		}
+	}
+}
+
+func TestFakeVerifyWebhook(t *testing.T) {
+	f := NewFake("secret")
+	body := `{"type":"payment.refunded","reference":"fake_1","amount":500}`
+
+	request := httptest.NewRequest("POST", "/payments/webhook", strings.NewReader(body))
+	request.Header.Set(FakeSignatureHeader, f.Sign([]byte(body)))
+	event, err := f.VerifyWebhook(request)
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if event != (WebhookEvent{Type: EventRefunded, Reference: "fake_1", Amount: 500}) {
+		t.Errorf("event = %+v", event)
+	}
+
+	cases := []struct {
+		name, signature, body string
+		secret                string
+		want                  error
+	}{
+		{"missing signature", "", body, "secret", ErrInvalidSignature},
+		{"signature not hex", "zz", body, "secret", ErrInvalidSignature},
+		{"signed with another secret", NewFake("other").Sign([]byte(body)), body, "secret", ErrInvalidSignature},
+		{"body changed after signing", f.Sign([]byte(body)), strings.Replace(body, "500", "50000", 1), "secret", ErrInvalidSignature},
+		{"no secret configured", NewFake("").Sign([]byte(body)), body, "", nil},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("POST", "/payments/webhook", strings.NewReader(c.body))
+		request.Header.Set(FakeSignatureHeader, c.signature)
+		_, err := NewFake(c.secret).VerifyWebhook(request)
+		if err == nil {
+			t.Errorf("%s: accepted, want error", c.name)
+			continue
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	signed := `not json`
+	request = httptest.NewRequest("POST", "/payments/webhook", strings.NewReader(signed))
+	request.Header.Set(FakeSignatureHeader, f.Sign([]byte(signed)))
+	if _, err := f.VerifyWebhook(request); err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signed invalid body: err = %v, want a body error", err)
+	}
+}
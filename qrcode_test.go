@@ -0,0 +1,122 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Byte mode capacities at level M, versions 1 to 10 (ISO/IEC 18004 table 7)
+var byteCapacity = []int{14, 26, 42, 62, 84, 106, 122, 152, 180, 213}
+
+// Format information for level M and masks 0 to 7, after the 0x5412 mask (ISO/IEC 18004 annex C)
+var formatInfoM = []int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0}
+
+// Version information for versions 7 to 10 (ISO/IEC 18004 annex D)
+var versionInfo = map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3}
+
+func TestEncodeCapacity(t *testing.T) {
+	for i, capacity := range byteCapacity {
+		version := i + 1
+		code, err := Encode(bytes.Repeat([]byte("a"), capacity))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", capacity, err)
+		}
+		if code.Version != version || code.Size != version*4+17 {
+			t.Errorf("Encode(%d bytes): version %d size %d, want version %d size %d", capacity, code.Version, code.Size, version, version*4+17)
+		}
+		if version < len(byteCapacity) {
+			if code, _ := Encode(bytes.Repeat([]byte("a"), capacity+1)); code.Version != version+1 {
+				t.Errorf("Encode(%d bytes): version %d, want %d", capacity+1, code.Version, version+1)
+			}
+		}
+	}
+	if _, err := Encode(bytes.Repeat([]byte("a"), 214)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode(214 bytes): err = %v, want ErrTooLong", err)
+	}
+}
+
+// readBits reads the modules at positions as a number, the first position being the least significant bit
+func readBits(c *Code, positions [][2]int) int {
+	bits := 0
+	for i, p := range positions {
+		if c.Dark(p[0], p[1]) {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+func TestFormatAndVersionInformation(t *testing.T) {
+	for version := 1; version <= 10; version++ {
+		code, err := Encode(bytes.Repeat([]byte("https://example.com/"), 11)[:byteCapacity[version-1]])
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		size := code.Size
+
+		// One copy runs around the top left finder, the other is split between the other two
+		var first, second [][2]int
+		for i := 0; i <= 5; i++ {
+			first = append(first, [2]int{8, i})
+		}
+		first = append(first, [2]int{8, 7}, [2]int{8, 8}, [2]int{7, 8})
+		for i := 9; i < 15; i++ {
+			first = append(first, [2]int{14 - i, 8})
+		}
+		for i := 0; i < 8; i++ {
+			second = append(second, [2]int{size - 1 - i, 8})
+		}
+		for i := 8; i < 15; i++ {
+			second = append(second, [2]int{8, size - 15 + i})
+		}
+
+		format := readBits(code, first)
+		if readBits(code, second) != format {
+			t.Errorf("version %d: format copies differ, %015b and %015b", version, format, readBits(code, second))
+		}
+		valid := false
+		for _, want := range formatInfoM {
+			valid = valid || format == want
+		}
+		if !valid {
+			t.Errorf("version %d: format information %015b is not a level M codeword", version, format)
+		}
+		if !code.Dark(8, size-8) {
+			t.Errorf("version %d: dark module is light", version)
+		}
+
+		want, ok := versionInfo[version]
+		if !ok {
+			continue
+		}
+		var topRight, bottomLeft [][2]int
+		for i := 0; i < 18; i++ {
+			topRight = append(topRight, [2]int{size - 11 + i%3, i / 3})
+			bottomLeft = append(bottomLeft, [2]int{i / 3, size - 11 + i%3})
+		}
+		if got := readBits(code, topRight); got != want {
+			t.Errorf("version %d: version information %018b, want %018b", version, got, want)
+		}
+		if got := readBits(code, bottomLeft); got != want {
+			t.Errorf("version %d: second version information %018b, want %018b", version, got, want)
+		}
+	}
+}
+
+func TestFinderPatterns(t *testing.T) {
+	code, err := Encode([]byte("https://example.com/checkin?token=abc"))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if want := ring != 2; code.Dark(corner[0]+dx, corner[1]+dy) != want {
+					t.Errorf("finder at %v: module (%d, %d) dark = %v, want %v", corner, dx, dy, !want, want)
+				}
+			}
+		}
+	}
+}
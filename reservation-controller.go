@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultReservationMinutes = 90
+	minReservationMinutes     = 15
+	maxReservationMinutes     = 6 * 60
+	// Bookings start on the half hour; opening hours are checked every quarter of an hour
+	reservationSlotStep  = 30 * time.Minute
+	reservationHoursStep = 15 * time.Minute
+)
+
+var reservationColumns = []string{
+	"reservations.id",
+	"reservations.table_id",
+	"tables.vendor_id",
+	"reservations.customer_id",
+	"reservations.party_size",
+	"reservations.starts_at",
+	"reservations.ends_at",
+	"reservations.status",
+	"reservations.created_at",
+	"reservations.updated_at",
+}
+
+var reservationListSpec = listSpec{
+	Sortable: map[string]string{
+		"starts_at":  "reservations.starts_at",
+		"created_at": "reservations.created_at",
+	},
+	Filterable: map[string]string{
+		"status":      "reservations.status",
+		"table_id":    "reservations.table_id",
+		"vendor_id":   "tables.vendor_id",
+		"customer_id": "reservations.customer_id",
+	},
+	DefaultSort: "starts_at",
+	TieBreaker:  []string{"reservations.id"},
+}
+
+func selectReservations() squirrel.SelectBuilder {
+	return QB.Select(reservationColumns...).
+		From("reservations").
+		Join("tables ON tables.id = reservations.table_id")
+}
+
+// reservationVisibility limits reservations to those booked by the current user or at the vendors they manage
+func reservationVisibility(r *http.Request) (squirrel.Sqlizer, error) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		return squirrel.Expr("1 = 0"), nil
+	}
+	vendorIDs, restricted, err := vendorScope(r)
+	if err != nil || !restricted {
+		return nil, err
+	}
+	return squirrel.Or{
+		squirrel.Eq{"reservations.customer_id": user.ID},
+		squirrel.Eq{"tables.vendor_id": vendorIDs},
+	}, nil
+}
+
+// loadReservation fetches a reservation and writes the error response when the current user
+// neither booked it nor manages its vendor
+func loadReservation(w http.ResponseWriter, r *http.Request, id string) (models.Reservation, bool) {
+	var reservation models.Reservation
+	query, args, err := selectReservations().Where(squirrel.Eq{"reservations.id": id}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return reservation, false
+	}
+	if err := db.Get(&reservation, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Reservation not found")
+			return reservation, false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return reservation, false
+	}
+	if user, ok := CurrentUser(r); ok && user.ID == reservation.CustomerID {
+		return reservation, true
+	}
+	allowed, err := canManageVendor(r, reservation.VendorID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor access: "+err.Error())
+		return reservation, false
+	}
+	if !allowed {
+		utils.HandelError(w, http.StatusForbidden, "You are not allowed to access this reservation")
+		return reservation, false
+	}
+	return reservation, true
+}
+
+// reservationDuration reads the duration field in minutes, defaulting to a standard sitting
+func reservationDuration(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	minutes := defaultReservationMinutes
+	if !formInt(w, r, "duration", &minutes) {
+		return 0, false
+	}
+	if minutes < minReservationMinutes || minutes > maxReservationMinutes {
+		utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("duration must be between %d and %d minutes", minReservationMinutes, maxReservationMinutes))
+		return 0, false
+	}
+	return time.Duration(minutes) * time.Minute, true
+}
+
+// partySize reads the required party size field
+func partySize(w http.ResponseWriter, r *http.Request, field string) (int, bool) {
+	size, err := strconv.Atoi(r.FormValue(field))
+	if err != nil || size < 1 {
+		utils.HandelError(w, http.StatusBadRequest, field+" must be a positive number")
+		return 0, false
+	}
+	return size, true
+}
+
+// vendorOpenThrough reports whether the vendor's hours and closures cover the whole period.
+// The pause switch only holds back orders placed now, so it does not block bookings.
+func vendorOpenThrough(vendor models.Vendor, start, end time.Time) bool {
+	vendor.IsPaused = false
+	for t := start; t.Before(end); t = t.Add(reservationHoursStep) {
+		if open, _ := vendorOpenAt(vendor, t); !open {
+			return false
+		}
+	}
+	open, _ := vendorOpenAt(vendor, end.Add(-time.Minute))
+	return open
+}
+
+// isReservationOverlap reports whether err is the exclusion constraint rejecting a double booking
+func isReservationOverlap(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Constraint == "excl_reservations_overlap"
+}
+
+// IndexReservationsHandler handles GET requests for the reservations the current user can see
+func IndexReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	reservations := []models.Reservation{}
+	selectQuery := selectReservations()
+	visibility, err := reservationVisibility(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if visibility != nil {
+		selectQuery = selectQuery.Where(visibility)
+	}
+	meta, ok := paginate(w, r, selectQuery, reservationListSpec, &reservations)
+	if !ok {
+		return
+	}
+	sendList(w, meta, reservations)
+}
+
+// ShowReservationHandler handles GET requests to fetch a single reservation by ID
+func ShowReservationHandler(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := loadReservation(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, reservation)
+}
+
+// CreateReservationHandler handles POST requests from a customer booking a table
+//
+//	POST /reservations  table_id=<id>  party_size=4  starts_at=2024-06-01T19:30:00+02:00  duration=120
+func CreateReservationHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	if r.FormValue("table_id") == "" || r.FormValue("party_size") == "" || r.FormValue("starts_at") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "table_id, party_size and starts_at are required")
+		return
+	}
+
+	tableID, err := uuid.Parse(r.FormValue("table_id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid table_id format")
+		return
+	}
+	party, ok := partySize(w, r, "party_size")
+	if !ok {
+		return
+	}
+	startsAt, err := time.Parse(time.RFC3339, r.FormValue("starts_at"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid starts_at format, expected RFC 3339")
+		return
+	}
+	if !startsAt.After(time.Now()) {
+		utils.HandelError(w, http.StatusBadRequest, "Reservations must start in the future")
+		return
+	}
+	duration, ok := reservationDuration(w, r)
+	if !ok {
+		return
+	}
+	endsAt := startsAt.Add(duration)
+
+	var table models.Table
+	query, args, err := QB.Select(strings.Join(table_columns, ", ")).From("tables").Where(squirrel.Eq{"id": tableID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Get(&table, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Table not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if party > table.Capacity {
+		utils.HandelError(w, http.StatusConflict, fmt.Sprintf("%s seats only %d", table.Name, table.Capacity))
+		return
+	}
+	vendor, err := loadScheduledVendor(db, table.VendorID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor hours: "+err.Error())
+		return
+	}
+	if !vendorOpenThrough(vendor, startsAt, endsAt) {
+		utils.HandelError(w, http.StatusConflict, vendor.Name+" is not open for the whole reservation")
+		return
+	}
+
+	id := uuid.New()
+	query, args, err = QB.Insert("reservations").
+		Columns("id", "table_id", "customer_id", "party_size", "starts_at", "ends_at", "status").
+		Values(id, tableID, user.ID, party, startsAt, endsAt, models.ReservationBooked).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		if isReservationOverlap(err) {
+			utils.HandelError(w, http.StatusConflict, "Table is already booked for this time")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating reservation: "+err.Error())
+		return
+	}
+
+	reservation, ok := loadReservation(w, r, id.String())
+	if !ok {
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, reservation)
+}
+
+// CancelReservationHandler handles POST requests cancelling a booking, by the customer or the vendor's staff
+func CancelReservationHandler(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := loadReservation(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	setReservationStatus(w, r, reservation, models.ReservationCancelled)
+}
+
+// NoShowReservationHandler handles POST requests from vendor staff marking a party that never arrived,
+// which frees the rest of the booked period
+func NoShowReservationHandler(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := loadReservation(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if !authorizeVendor(w, r, reservation.VendorID) {
+		return
+	}
+	if time.Now().Before(reservation.StartsAt) {
+		utils.HandelError(w, http.StatusConflict, "Reservation has not started yet")
+		return
+	}
+	setReservationStatus(w, r, reservation, models.ReservationNoShow)
+}
+
+// setReservationStatus moves a booked reservation to status; the status guard in the UPDATE
+// keeps a concurrent cancel and no-show from both succeeding
+func setReservationStatus(w http.ResponseWriter, r *http.Request, reservation models.Reservation, status models.ReservationStatus) {
+	query, args, err := QB.Update("reservations").
+		Set("status", status).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": reservation.ID, "status": models.ReservationBooked}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating reservation: "+err.Error())
+		return
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+		utils.HandelError(w, http.StatusConflict, fmt.Sprintf("Reservation is %s, only booked reservations can change", reservation.Status))
+		return
+	}
+
+	reservation, ok := loadReservation(w, r, reservation.ID.String())
+	if !ok {
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, reservation)
+}
+
+// VendorAvailabilityHandler handles GET requests for the times a vendor's tables can be booked on a day.
+// The date is read in the vendor's timezone.
+//
+//	GET /vendors/{id}/availability?date=2024-06-01&party=4&duration=120
+func VendorAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if r.FormValue("date") == "" || r.FormValue("party") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "date and party are required")
+		return
+	}
+	party, ok := partySize(w, r, "party")
+	if !ok {
+		return
+	}
+	duration, ok := reservationDuration(w, r)
+	if !ok {
+		return
+	}
+
+	vendor, err := loadScheduledVendor(db, vendorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Vendor not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor hours: "+err.Error())
+		return
+	}
+	location, err := time.LoadLocation(vendor.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	dayStart, err := time.ParseInLocation(time.DateOnly, r.FormValue("date"), location)
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid date format, expected YYYY-MM-DD")
+		return
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	// Only tables that seat the whole party are offered
+	var tableIDs []uuid.UUID
+	query, args, err := QB.Select("id").
+		From("tables").
+		Where(squirrel.Eq{"vendor_id": vendorID}).
+		Where("capacity >= ?", party).
+		OrderBy("capacity", "name", "id").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Select(&tableIDs, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	booked, err := bookedPeriods(db, vendorID, dayStart, dayEnd.Add(duration))
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error fetching reservations: "+err.Error())
+		return
+	}
+
+	slots := []models.ReservationSlot{}
+	now := time.Now()
+	for start := dayStart; start.Before(dayEnd); start = start.Add(reservationSlotStep) {
+		end := start.Add(duration)
+		if !start.After(now) || !vendorOpenThrough(vendor, start, end) {
+			continue
+		}
+		slot := models.ReservationSlot{StartsAt: start, EndsAt: end, TableIDs: []uuid.UUID{}}
+		for _, tableID := range tableIDs {
+			free := true
+			for _, period := range booked[tableID] {
+				if period.StartsAt.Before(end) && start.Before(period.EndsAt) {
+					free = false
+					break
+				}
+			}
+			if free {
+				slot.TableIDs = append(slot.TableIDs, tableID)
+			}
+		}
+		if len(slot.TableIDs) > 0 {
+			slots = append(slots, slot)
+		}
+	}
+	utils.SendJSONResponse(w, http.StatusOK, slots)
+}
+
+// bookedPeriods returns the live bookings at the vendor's tables that overlap from..to, by table
+func bookedPeriods(q sqlx.Queryer, vendorID uuid.UUID, from, to time.Time) (map[uuid.UUID][]models.Reservation, error) {
+	var reservations []models.Reservation
+	query, args, err := selectReservations().
+		Where(squirrel.Eq{"tables.vendor_id": vendorID, "reservations.status": models.ReservationBooked}).
+		Where("reservations.starts_at < ? AND reservations.ends_at > ?", to, from).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &reservations, query, args...); err != nil {
+		return nil, err
+	}
+	byTable := map[uuid.UUID][]models.Reservation{}
+	for _, reservation := range reservations {
+		byTable[reservation.TableID] = append(byTable[reservation.TableID], reservation)
+	}
+	return byTable, nil
+}
@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var sessionColumns = []string{
+	"id",
+	"user_id",
+	"family_id",
+	"token_hash",
+	"expires_at",
+	"revoked_at",
+	"replaced_by",
+	"created_at",
+}
+
+var errRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// RefreshTokenHandler handles POST requests that exchange a refresh token for a new token pair
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	refreshToken := refreshTokenFromRequest(r)
+	if refreshToken == "" {
+		utils.HandelError(w, http.StatusUnauthorized, "Missing refresh token")
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	tokens, err := rotateSession(tx, refreshToken)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			// The family was revoked inside the transaction, keep that change
+			tx.Commit()
+			clearAuthCookies(w)
+			utils.HandelError(w, http.StatusUnauthorized, "Refresh token has already been used, please log in again")
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error refreshing session: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+
+	setAuthCookies(w, tokens)
+	utils.SendJSONResponse(w, http.StatusOK, tokens)
+}
+
+// LogoutHandler handles POST requests that end the current session and clear the auth cookies
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var familyID uuid.UUID
+	if refreshToken := refreshTokenFromRequest(r); refreshToken != "" {
+		query, args, err := QB.Select("family_id").
+			From("sessions").
+			Where(squirrel.Eq{"token_hash": hashToken(refreshToken)}).
+			ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query")
+			return
+		}
+		if err := db.Get(&familyID, query, args...); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusInternalServerError, "Error fetching session: "+err.Error())
+			return
+		}
+	} else if accessToken := tokenFromRequest(r); accessToken != "" {
+		// An expired access token still identifies the session to end
+		if claims, err := parseAccessToken(accessToken); err == nil || errors.Is(err, errAccessTokenExpired) {
+			familyID = claims.SessionID
+		}
+	}
+
+	if familyID != uuid.Nil {
+		if err := revokeSessions(db, squirrel.Eq{"family_id": familyID}); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error revoking session: "+err.Error())
+			return
+		}
+	}
+
+	clearAuthCookies(w)
+	utils.SendJSONResponse(w, http.StatusOK, "Logged out")
+}
+
+// LogoutAllHandler handles POST requests that end every session of the authenticated user
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		utils.HandelError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	if err := revokeSessions(db, squirrel.Eq{"user_id": user.ID}); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error revoking sessions: "+err.Error())
+		return
+	}
+
+	clearAuthCookies(w)
+	utils.SendJSONResponse(w, http.StatusOK, "Logged out from all sessions")
+}
+
+// startSession creates a new session family for the user and returns its first token pair
+func startSession(userID uuid.UUID) (models.TokenPair, error) {
+	return createSession(db, userID, uuid.New())
+}
+
+// createSession stores a new refresh token in the given family and signs a matching access token
+func createSession(exec sqlx.Ext, userID, familyID uuid.UUID) (models.TokenPair, error) {
+	var tokens models.TokenPair
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return tokens, err
+	}
+
+	session := models.Session{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
+	}
+	query, args, err := QB.Insert("sessions").
+		Columns("id", "user_id", "family_id", "token_hash", "expires_at").
+		Values(session.ID, session.UserID, session.FamilyID, session.TokenHash, session.ExpiresAt).
+		ToSql()
+	if err != nil {
+		return tokens, err
+	}
+	if _, err := exec.Exec(query, args...); err != nil {
+		return tokens, err
+	}
+
+	accessToken, expiresAt, err := signAccessToken(userID, familyID)
+	if err != nil {
+		return tokens, err
+	}
+
+	tokens = models.TokenPair{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+	}
+	return tokens, nil
+}
+
+// rotateSession revokes the presented refresh token and issues its successor in the same family.
+// Presenting a token that was already rotated or revoked revokes the whole family.
+func rotateSession(tx *sqlx.Tx, refreshToken string) (models.TokenPair, error) {
+	var session models.Session
+	query, args, err := QB.Select(strings.Join(sessionColumns, ", ")).
+		From("sessions").
+		Where(squirrel.Eq{"token_hash": hashToken(refreshToken)}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if err := tx.Get(&session, query, args...); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if session.RevokedAt != nil {
+		if err := revokeSessions(tx, squirrel.Eq{"family_id": session.FamilyID}); err != nil {
+			return models.TokenPair{}, err
+		}
+		return models.TokenPair{}, errRefreshTokenReused
+	}
+	if time.Now().UTC().After(session.ExpiresAt) {
+		return models.TokenPair{}, sql.ErrNoRows
+	}
+
+	tokens, err := createSession(tx, session.UserID, session.FamilyID)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	query, args, err = QB.Update("sessions").
+		Set("revoked_at", time.Now().UTC()).
+		Set("replaced_by", squirrel.Expr("(SELECT id FROM sessions WHERE token_hash = ?)", hashToken(tokens.RefreshToken))).
+		Where(squirrel.Eq{"id": session.ID}).
+		ToSql()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return models.TokenPair{}, err
+	}
+	return tokens, nil
+}
+
+// revokeSessions marks every still-active session matching the condition as revoked
+func revokeSessions(exec sqlx.Execer, where squirrel.Sqlizer) error {
+	query, args, err := QB.Update("sessions").
+		Set("revoked_at", time.Now().UTC()).
+		Where(where).
+		Where("revoked_at IS NULL").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = exec.Exec(query, args...)
+	return err
+}
+
+// sessionFamilyActive reports whether the family still has a usable refresh token
+func sessionFamilyActive(familyID uuid.UUID) (bool, error) {
+	query, args, err := QB.Select("1").
+		From("sessions").
+		Where(squirrel.Eq{"family_id": familyID}).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now().UTC()).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	var active bool
+	if err := db.Get(&active, query, args...); err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// signAccessToken issues a short-lived JWT bound to a session family
+func signAccessToken(userID, familyID uuid.UUID) (string, time.Time, error) {
+	expiresAt := time.Now().UTC().Add(accessTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID.String(),
+		"sid":     familyID.String(),
+		"exp":     expiresAt.Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return signed, expiresAt, err
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken keeps only a digest of refresh tokens in the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func refreshTokenFromRequest(r *http.Request) string {
+	if token := r.FormValue("refresh_token"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie("refreshToken"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func setAuthCookies(w http.ResponseWriter, tokens models.TokenPair) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "accessToken",
+		Value:    tokens.Token,
+		Path:     "/",
+		Expires:  tokens.ExpiresAt,
+		HttpOnly: true,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refreshToken",
+		Value:    tokens.RefreshToken,
+		Path:     "/users",
+		Expires:  tokens.RefreshExpiresAt,
+		HttpOnly: true,
+	})
+}
+
+func clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: "accessToken", Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	http.SetCookie(w, &http.Cookie{Name: "refreshToken", Value: "", Path: "/users", MaxAge: -1, HttpOnly: true})
+}
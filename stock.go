@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// itemUnavailableError is an item that cannot be ordered in the requested quantity
+type itemUnavailableError string
+
+func (e itemUnavailableError) Error() string { return string(e) }
+
+type itemStock struct {
+	Name        string `db:"name"`
+	IsAvailable bool   `db:"is_available"`
+	Stock       *int   `db:"stock"`
+}
+
+// checkItemAvailable reports an itemUnavailableError when the item is switched off or
+// has less tracked stock than quantity
+func checkItemAvailable(q sqlx.Queryer, itemID uuid.UUID, quantity int) error {
+	var item itemStock
+	query, args, err := QB.Select("name", "is_available", "stock").From("items").Where(squirrel.Eq{"id": itemID}).ToSql()
+	if err != nil {
+		return err
+	}
+	if err := sqlx.Get(q, &item, query, args...); err != nil {
+		return err
+	}
+	if !item.IsAvailable {
+		if item.Stock != nil && *item.Stock == 0 {
+			return itemUnavailableError(item.Name + " is sold out")
+		}
+		return itemUnavailableError(item.Name + " is currently unavailable")
+	}
+	if item.Stock != nil && *item.Stock < quantity {
+		return itemUnavailableError(fmt.Sprintf("Only %d of %s left", *item.Stock, item.Name))
+	}
+	return nil
+}
+
+// sortedItemIDs returns the items in id order, the order stock rows are locked in to avoid
+// deadlocks between concurrent orders
+func sortedItemIDs(quantities map[uuid.UUID]int) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
+
+// reserveStock takes the ordered quantities out of the items' tracked stock, flipping an item to
+// unavailable and sold out when it reaches zero. Each decrement is a single conditional UPDATE so concurrent
+// orders cannot oversell; items are updated in id order to avoid deadlocks between them.
+func reserveStock(q sqlx.Queryer, quantities map[uuid.UUID]int) error {
+	for _, id := range sortedItemIDs(quantities) {
+		quantity := quantities[id]
+		// A negative quantity would put stock back and pass the guard below
+		if quantity <= 0 {
+			return fmt.Errorf("cannot reserve %d of item %s", quantity, id)
+		}
+		var updated uuid.UUID
+		query, args, err := QB.Update("items").
+			Set("stock", squirrel.Expr("stock - ?", quantity)).
+			Set("is_available", squirrel.Expr("stock IS NULL OR stock > ?", quantity)).
+			Set("sold_out", squirrel.Expr("stock IS NOT NULL AND stock = ?", quantity)).
+			Where(squirrel.Eq{"id": id, "is_available": true}).
+			Where(squirrel.Expr("(stock IS NULL OR stock >= ?)", quantity)).
+			Suffix("RETURNING id").
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if err := sqlx.Get(q, &updated, query, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				if err := checkItemAvailable(q, id, quantity); err != nil {
+					return err
+				}
+				return itemUnavailableError("Item is no longer available")
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseStock puts quantities back into the items' tracked stock, as when an order line is
+// reduced or removed. An item that had sold out becomes available again, one the vendor
+// switched off stays off.
+func releaseStock(q sqlx.Execer, quantities map[uuid.UUID]int) error {
+	for _, id := range sortedItemIDs(quantities) {
+		quantity := quantities[id]
+		if quantity <= 0 {
+			return fmt.Errorf("cannot release %d of item %s", quantity, id)
+		}
+		query, args, err := QB.Update("items").
+			Set("stock", squirrel.Expr("stock + ?", quantity)).
+			Set("is_available", squirrel.Expr("is_available OR sold_out")).
+			Set("sold_out", false).
+			Where(squirrel.Eq{"id": id}).
+			Where("stock IS NOT NULL").
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := q.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseOrderStock puts the quantities of all the order's lines back into stock,
+// used when an open order is cancelled, rejected or deleted
+func releaseOrderStock(tx *sqlx.Tx, orderID uuid.UUID) error {
+	var lines []struct {
+		ItemID   uuid.UUID `db:"item_id"`
+		Quantity int       `db:"quantity"`
+	}
+	query, args, err := QB.Select("item_id", "quantity").From("order_items").
+		Where(squirrel.Eq{"order_id": orderID}).
+		Where("quantity > 0").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.Select(&lines, query, args...); err != nil {
+		return err
+	}
+	quantities := map[uuid.UUID]int{}
+	for _, line := range lines {
+		quantities[line.ItemID] += line.Quantity
+	}
+	return releaseStock(tx, quantities)
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"intership/models"
+	"intership/qrcode"
+	"intership/utils"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// Check-in tokens are table id, nonce and a truncated HMAC, 48 bytes before encoding
+	checkinMACSize = 16
+	checkinQRScale = 8
+	maxQRScale     = 32
+)
+
+var errInvalidCheckinToken = errors.New("invalid check-in token")
+
+// checkinMAC signs a table's current nonce with the server secret
+func checkinMAC(tableID, nonce uuid.UUID) []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("table-checkin:"))
+	mac.Write(tableID[:])
+	mac.Write(nonce[:])
+	return mac.Sum(nil)[:checkinMACSize]
+}
+
+// signCheckinToken builds the token printed in a table's QR code
+func signCheckinToken(tableID, nonce uuid.UUID) string {
+	payload := make([]byte, 0, 32+checkinMACSize)
+	payload = append(payload, tableID[:]...)
+	payload = append(payload, nonce[:]...)
+	payload = append(payload, checkinMAC(tableID, nonce)...)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// parseCheckinToken checks the token signature and returns the table and nonce it was issued for.
+// The nonce still has to match the table's current one, rotating it revokes older tokens.
+func parseCheckinToken(token string) (uuid.UUID, uuid.UUID, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(payload) != 32+checkinMACSize {
+		return uuid.Nil, uuid.Nil, errInvalidCheckinToken
+	}
+	tableID, _ := uuid.FromBytes(payload[:16])
+	nonce, _ := uuid.FromBytes(payload[16:32])
+	if !hmac.Equal(payload[32:], checkinMAC(tableID, nonce)) {
+		return uuid.Nil, uuid.Nil, errInvalidCheckinToken
+	}
+	return tableID, nonce, nil
+}
+
+// checkinURL is the frontend page a scanned table code opens
+func checkinURL(token string) string {
+	frontend := os.Getenv("FRONTEND_URL")
+	if frontend == "" {
+		frontend = "http://localhost:3000"
+	}
+	return strings.TrimRight(frontend, "/") + "/checkin?token=" + url.QueryEscape(token)
+}
+
+// tableCheckinToken returns the current check-in token of a table the user manages
+func tableCheckinToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var table struct {
+		ID       uuid.UUID `db:"id"`
+		VendorID uuid.UUID `db:"vendor_id"`
+		Nonce    uuid.UUID `db:"checkin_nonce"`
+	}
+	query, args, err := QB.Select("id", "vendor_id", "checkin_nonce").From("tables").Where("id = ?", r.PathValue("id")).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return "", false
+	}
+	if err := db.Get(&table, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Table not found")
+			return "", false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return "", false
+	}
+	if !authorizeVendor(w, r, table.VendorID) {
+		return "", false
+	}
+	return signCheckinToken(table.ID, table.Nonce), true
+}
+
+// TableQRCodeHandler handles GET requests for the PNG QR code to print on a table
+//
+//	GET /tables/{id}/qr.png?scale=10
+func TableQRCodeHandler(w http.ResponseWriter, r *http.Request) {
+	scale := checkinQRScale
+	if value := r.URL.Query().Get("scale"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxQRScale {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid scale value")
+			return
+		}
+		scale = parsed
+	}
+
+	token, ok := tableCheckinToken(w, r)
+	if !ok {
+		return
+	}
+	code, err := qrcode.Encode([]byte(checkinURL(token)))
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error encoding QR code: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := code.WritePNG(w, scale); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error rendering QR code: "+err.Error())
+	}
+}
+
+// ShowTableCheckinTokenHandler handles GET requests for a table's check-in token and link
+func ShowTableCheckinTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := tableCheckinToken(w, r)
+	if !ok {
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"token": token, "url": checkinURL(token)})
+}
+
+// RotateTableCheckinTokenHandler handles POST requests issuing a new check-in token for a table,
+// which invalidates the printed QR code
+func RotateTableCheckinTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, QB.Select("vendor_id").From("tables").Where("id = ?", id)) {
+		return
+	}
+	var table struct {
+		ID    uuid.UUID `db:"id"`
+		Nonce uuid.UUID `db:"checkin_nonce"`
+	}
+	query, args, err := QB.Update("tables").
+		Set("checkin_nonce", uuid.New()).
+		Where("id = ?", id).
+		Suffix("RETURNING id, checkin_nonce").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&table); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error rotating check-in token: "+err.Error())
+		return
+	}
+	token := signCheckinToken(table.ID, table.Nonce)
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"token": token, "url": checkinURL(token)})
+}
+
+// seatWithCheckinNonce seats user when nonce is still the locked table's current one,
+// so a token revoked by a rotation committed in the meantime is turned away
+func seatWithCheckinNonce(w http.ResponseWriter, user models.User, nonce uuid.UUID) func(tx *sqlx.Tx, table *models.Table) bool {
+	seat := seatCustomer(w, user)
+	return func(tx *sqlx.Tx, table *models.Table) bool {
+		var current uuid.UUID
+		query, args, err := QB.Select("checkin_nonce").From("tables").Where(squirrel.Eq{"id": table.ID}).ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return false
+		}
+		if err := tx.Get(&current, query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		if current != nonce {
+			utils.HandelError(w, http.StatusBadRequest, "Check-in token has expired, scan the code on the table again")
+			return false
+		}
+		return seat(tx, table)
+	}
+}
+
+// CheckinTableHandler handles POST requests from a customer who scanned a table code,
+// seating them at that table
+func CheckinTableHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	tableID, nonce, err := parseCheckinToken(r.FormValue("token"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid check-in token")
+		return
+	}
+	withLockedTable(w, tableID.String(), seatWithCheckinNonce(w, user, nonce))
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Table event types sent on GET /vendors/{id}/table-events
+const (
+	tableSeatedEvent              = "table.seated"
+	tableServiceRequestedEvent    = "table.service_requested"
+	tableServiceAcknowledgedEvent = "table.service_acknowledged"
+	tableReleasedEvent            = "table.released"
+)
+
+var serviceCallColumns = []string{
+	"service_calls.id",
+	"service_calls.table_id",
+	"tables.name AS table_name",
+	"tables.zone",
+	"service_calls.customer_id",
+	"service_calls.requested_at",
+	"service_calls.acknowledged_at",
+	"service_calls.acknowledged_by",
+	// Open calls count up to now, acknowledged ones up to when staff took them
+	"EXTRACT(EPOCH FROM COALESCE(service_calls.acknowledged_at, CURRENT_TIMESTAMP) - service_calls.requested_at)::int AS waiting_seconds",
+}
+
+func selectServiceCalls() squirrel.SelectBuilder {
+	return QB.Select(serviceCallColumns...).
+		From("service_calls").
+		Join("tables ON tables.id = service_calls.table_id")
+}
+
+func getServiceCall(tx *sqlx.Tx, id uuid.UUID) (models.ServiceCall, error) {
+	var call models.ServiceCall
+	query, args, err := selectServiceCalls().Where(squirrel.Eq{"service_calls.id": id}).ToSql()
+	if err != nil {
+		return call, err
+	}
+	return call, tx.Get(&call, query, args...)
+}
+
+// openServiceCall files a call for the table unless one is already waiting.
+// It returns the waiting call and whether this request created it.
+func openServiceCall(tx *sqlx.Tx, table *models.Table) (models.ServiceCall, bool, error) {
+	var id uuid.UUID
+	query, args, err := QB.Insert("service_calls").
+		Columns("id", "table_id", "customer_id").
+		Values(uuid.New(), table.ID, table.CustomerID).
+		Suffix("ON CONFLICT (table_id) WHERE acknowledged_at IS NULL DO NOTHING RETURNING id").
+		ToSql()
+	if err != nil {
+		return models.ServiceCall{}, false, err
+	}
+	created := true
+	if err := tx.Get(&id, query, args...); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return models.ServiceCall{}, false, err
+		}
+		created = false
+		query, args, err = QB.Select("id").
+			From("service_calls").
+			Where(squirrel.Eq{"table_id": table.ID, "acknowledged_at": nil}).
+			ToSql()
+		if err != nil {
+			return models.ServiceCall{}, false, err
+		}
+		if err := tx.Get(&id, query, args...); err != nil {
+			return models.ServiceCall{}, false, err
+		}
+	}
+	call, err := getServiceCall(tx, id)
+	return call, created, err
+}
+
+// acknowledgeServiceCall marks the table's waiting call as taken by userID, or just closes it when userID is nil.
+// It returns nil when no call was waiting.
+func acknowledgeServiceCall(tx *sqlx.Tx, tableID uuid.UUID, userID *uuid.UUID) (*models.ServiceCall, error) {
+	var id uuid.UUID
+	query, args, err := QB.Update("service_calls").
+		Set("acknowledged_at", squirrel.Expr("CURRENT_TIMESTAMP")).
+		Set("acknowledged_by", userID).
+		Where(squirrel.Eq{"table_id": tableID, "acknowledged_at": nil}).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Get(&id, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	call, err := getServiceCall(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &call, nil
+}
+
+// notifyTable announces a table change on its vendor's table event stream once the transaction commits
+func notifyTable(tx *sqlx.Tx, eventType string, table models.Table, call *models.ServiceCall) error {
+	return publishEvent(tx, tableEventsChannel, eventType, table.VendorID, uuid.Nil, models.TableEvent{Table: table, ServiceCall: call})
+}
+
+// IndexServiceCallsHandler handles GET requests for a vendor's unanswered service calls, longest waiting first
+func IndexServiceCallsHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+
+	calls := []models.ServiceCall{}
+	query, args, err := selectServiceCalls().
+		Where(squirrel.Eq{"tables.vendor_id": vendorID, "service_calls.acknowledged_at": nil}).
+		OrderBy("service_calls.requested_at", "service_calls.id").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.Select(&calls, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, calls)
+}
+
+// StreamTableEventsHandler handles GET requests for a Server-Sent Events stream of a vendor's tables
+// being seated, calling for service, having the call acknowledged and being released.
+// Clients load GET /vendors/{id}/service-calls first and apply the events on top.
+func StreamTableEventsHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+	serveEventStream(w, r, tableEventsChannel, func(event streamEvent) bool {
+		return event.VendorID == vendorID
+	})
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// finalOrderStatuses are the statuses of orders the floor staff no longer have to act on
+var finalOrderStatuses = []models.OrderStatus{models.Completed, models.Cancelled, models.Rejected}
+
+// validateOrderTable checks that the table exists and belongs to the vendor the order is placed with
+func validateOrderTable(w http.ResponseWriter, q sqlx.Queryer, tableID, vendorID uuid.UUID) bool {
+	var tableVendorID uuid.UUID
+	query, args, err := QB.Select("vendor_id").From("tables").Where(squirrel.Eq{"id": tableID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return false
+	}
+	if err := sqlx.Get(q, &tableVendorID, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusBadRequest, "Table not found")
+			return false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if tableVendorID != vendorID {
+		utils.HandelError(w, http.StatusBadRequest, "Table belongs to another vendor")
+		return false
+	}
+	return true
+}
+
+// authorizeOrderTable lets an order name a table only when its customer is seated there
+// or the current user runs the vendor
+func authorizeOrderTable(w http.ResponseWriter, r *http.Request, q sqlx.Queryer, tableID, vendorID, customerID uuid.UUID) bool {
+	var seated bool
+	query, args, err := QB.Select("1").
+		From("tables").
+		Where(squirrel.Eq{"id": tableID, "customer_id": customerID}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return false
+	}
+	if err := sqlx.Get(q, &seated, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if seated {
+		return true
+	}
+	staff, err := canManageVendor(r, vendorID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if !staff {
+		utils.HandelError(w, http.StatusForbidden, "You can only order for the table you are seated at")
+		return false
+	}
+	return true
+}
+
+// orderPlacement reads order_type and table_id for a new order. Without an order_type, a customer
+// seated at one of the vendor's tables orders dine-in at that table and anyone else orders takeaway.
+func orderPlacement(w http.ResponseWriter, r *http.Request, q sqlx.Queryer, vendorID, customerID uuid.UUID) (models.OrderType, *uuid.UUID, bool) {
+	orderType := models.OrderType(r.FormValue("order_type"))
+	if orderType != "" && !orderType.IsValid() {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid order_type, expected dine_in, takeaway or delivery")
+		return "", nil, false
+	}
+
+	if r.FormValue("table_id") != "" {
+		tableID, err := uuid.Parse(r.FormValue("table_id"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid table_id format")
+			return "", nil, false
+		}
+		if orderType != "" && orderType != models.DineIn {
+			utils.HandelError(w, http.StatusBadRequest, "Only dine-in orders can be placed for a table")
+			return "", nil, false
+		}
+		if !validateOrderTable(w, q, tableID, vendorID) || !authorizeOrderTable(w, r, q, tableID, vendorID, customerID) {
+			return "", nil, false
+		}
+		return models.DineIn, &tableID, true
+	}
+
+	if orderType != "" && orderType != models.DineIn {
+		return orderType, nil, true
+	}
+
+	var seatedAt []uuid.UUID
+	query, args, err := QB.Select("id").
+		From("tables").
+		Where(squirrel.Eq{"vendor_id": vendorID, "customer_id": customerID}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return "", nil, false
+	}
+	if err := sqlx.Select(q, &seatedAt, query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return "", nil, false
+	}
+	if len(seatedAt) == 0 {
+		if orderType == models.DineIn {
+			utils.HandelError(w, http.StatusBadRequest, "Dine-in orders need a table_id")
+			return "", nil, false
+		}
+		return models.Takeaway, nil, true
+	}
+	return models.DineIn, &seatedAt[0], true
+}
+
+// IndexTableOrdersHandler handles GET requests for the orders still open at a table
+func IndexTableOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, QB.Select("vendor_id").From("tables").Where("id = ?", id)) {
+		return
+	}
+	orders := []models.Order{}
+	selectQuery := QB.Select(strings.Join(order_columns, ", ")).
+		From("orders").
+		Where(squirrel.Eq{"table_id": id}).
+		Where(squirrel.NotEq{"status": finalOrderStatuses})
+	meta, ok := paginate(w, r, selectQuery, orderListSpec, &orders)
+	if !ok {
+		return
+	}
+	sendList(w, meta, orders)
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var tableSessionColumns = []string{
+	"id",
+	"table_id",
+	"customer_id",
+	"seated_at",
+	"released_at",
+	"service_calls",
+}
+
+var tableSessionListSpec = listSpec{
+	Sortable:    map[string]string{"seated_at": "seated_at", "released_at": "released_at"},
+	Filterable:  map[string]string{"customer_id": "customer_id"},
+	DefaultSort: "-seated_at",
+	TieBreaker:  []string{"id"},
+}
+
+// withLockedTable runs action in a transaction holding the row lock of the table, so two requests
+// cannot change the same table at once, then saves the table state and writes it back.
+// action writes its own error response and returns false to abort.
+func withLockedTable(w http.ResponseWriter, tableID string, action func(tx *sqlx.Tx, table *models.Table) bool) {
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	var table models.Table
+	query, args, err := QB.Select(strings.Join(table_columns, ", ")).
+		From("tables").
+		Where("id = ?", tableID).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.Get(&table, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Table not found")
+			return
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !action(tx, &table) {
+		return
+	}
+
+	query, args, err = QB.Update("tables").
+		Set("is_available", table.IsAvailable).
+		Set("customer_id", table.CustomerID).
+		Set("is_needs_service", table.IsNeedsService).
+		Where(squirrel.Eq{"id": table.ID}).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(table_columns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := tx.QueryRowx(query, args...).StructScan(&table); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating table: "+err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, table)
+}
+
+// isSeatedAt reports whether the current user is the customer seated at the table
+func isSeatedAt(r *http.Request, table *models.Table) bool {
+	user, _ := CurrentUser(r)
+	return table.CustomerID != nil && *table.CustomerID == user.ID
+}
+
+// updateOpenSession applies an update to the table's current session
+func updateOpenSession(tx *sqlx.Tx, tableID uuid.UUID, update squirrel.UpdateBuilder) error {
+	query, args, err := update.
+		Where(squirrel.Eq{"table_id": tableID, "released_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+// isSeatedElsewhere reports whether err is the unique index rejecting a second open session for a customer
+func isSeatedElsewhere(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "uq_table_sessions_open_customer"
+}
+
+// SeatTableHandler handles POST requests where a customer claims a free table.
+// Like a check-in it needs the token from the table's QR code.
+func SeatTableHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	tableID, nonce, err := parseCheckinToken(r.FormValue("token"))
+	if err != nil || tableID.String() != strings.ToLower(r.PathValue("id")) {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid check-in token")
+		return
+	}
+	withLockedTable(w, tableID.String(), seatWithCheckinNonce(w, user, nonce))
+}
+
+// seatCustomer returns the table action that seats user at a free table and opens a session
+func seatCustomer(w http.ResponseWriter, user models.User) func(tx *sqlx.Tx, table *models.Table) bool {
+	return func(tx *sqlx.Tx, table *models.Table) bool {
+		if !table.IsAvailable || table.CustomerID != nil {
+			utils.HandelError(w, http.StatusConflict, "Table is already taken")
+			return false
+		}
+
+		var seatedElsewhere int
+		query, args, err := QB.Select("COUNT(*)").
+			From("tables").
+			Where(squirrel.Eq{"customer_id": user.ID}).
+			ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return false
+		}
+		if err := tx.Get(&seatedElsewhere, query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		if seatedElsewhere > 0 {
+			utils.HandelError(w, http.StatusConflict, "You are already seated at another table")
+			return false
+		}
+
+		query, args, err = QB.Insert("table_sessions").
+			Columns("id", "table_id", "customer_id").
+			Values(uuid.New(), table.ID, user.ID).
+			ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return false
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			// A seat at another table committed since the check above
+			if isSeatedElsewhere(err) {
+				utils.HandelError(w, http.StatusConflict, "You are already seated at another table")
+				return false
+			}
+			utils.HandelError(w, http.StatusInternalServerError, "Error opening table session: "+err.Error())
+			return false
+		}
+
+		table.IsAvailable = false
+		table.CustomerID = &user.ID
+		table.IsNeedsService = false
+		if err := notifyTable(tx, tableSeatedEvent, *table, nil); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error publishing table event: "+err.Error())
+			return false
+		}
+		return true
+	}
+}
+
+// CallServiceTableHandler handles POST requests from the seated customer asking for a waiter
+func CallServiceTableHandler(w http.ResponseWriter, r *http.Request) {
+	withLockedTable(w, r.PathValue("id"), func(tx *sqlx.Tx, table *models.Table) bool {
+		if !isSeatedAt(r, table) {
+			utils.HandelError(w, http.StatusForbidden, "Only the customer seated at this table can call for service")
+			return false
+		}
+		if err := updateOpenSession(tx, table.ID, QB.Update("table_sessions").Set("service_calls", squirrel.Expr("service_calls + 1"))); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating table session: "+err.Error())
+			return false
+		}
+		call, created, err := openServiceCall(tx, table)
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error recording service call: "+err.Error())
+			return false
+		}
+		table.IsNeedsService = true
+		// Calling again while waiting only counts towards the session, the staff already know
+		if created {
+			if err := notifyTable(tx, tableServiceRequestedEvent, *table, &call); err != nil {
+				utils.HandelError(w, http.StatusInternalServerError, "Error publishing table event: "+err.Error())
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// ServiceDoneTableHandler handles POST requests from vendor staff acknowledging a service call
+func ServiceDoneTableHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	withLockedTable(w, r.PathValue("id"), func(tx *sqlx.Tx, table *models.Table) bool {
+		if !authorizeVendor(w, r, table.VendorID) {
+			return false
+		}
+		if !table.IsNeedsService {
+			utils.HandelError(w, http.StatusConflict, "Table has not called for service")
+			return false
+		}
+		call, err := acknowledgeServiceCall(tx, table.ID, &user.ID)
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error acknowledging service call: "+err.Error())
+			return false
+		}
+		table.IsNeedsService = false
+		if err := notifyTable(tx, tableServiceAcknowledgedEvent, *table, call); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error publishing table event: "+err.Error())
+			return false
+		}
+		return true
+	})
+}
+
+// ReleaseTableHandler handles POST requests freeing a table, by its seated customer or the vendor's staff.
+// A service call still waiting is closed with it.
+func ReleaseTableHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := CurrentUser(r)
+	withLockedTable(w, r.PathValue("id"), func(tx *sqlx.Tx, table *models.Table) bool {
+		// Only staff take a call; a guest leaving just closes it
+		var acknowledgedBy *uuid.UUID
+		if !isSeatedAt(r, table) {
+			if !authorizeVendor(w, r, table.VendorID) {
+				return false
+			}
+			acknowledgedBy = &user.ID
+		}
+		if table.CustomerID == nil && table.IsAvailable {
+			utils.HandelError(w, http.StatusConflict, "Table is not taken")
+			return false
+		}
+		if err := updateOpenSession(tx, table.ID, QB.Update("table_sessions").Set("released_at", time.Now())); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error closing table session: "+err.Error())
+			return false
+		}
+		call, err := acknowledgeServiceCall(tx, table.ID, acknowledgedBy)
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error closing service call: "+err.Error())
+			return false
+		}
+		table.IsAvailable = true
+		table.CustomerID = nil
+		table.IsNeedsService = false
+		if err := notifyTable(tx, tableReleasedEvent, *table, call); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error publishing table event: "+err.Error())
+			return false
+		}
+		return true
+	})
+}
+
+// IndexTableSessionsHandler handles GET requests for the seating history of a table
+func IndexTableSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !authorizeVendorOf(w, r, QB.Select("vendor_id").From("tables").Where("id = ?", id)) {
+		return
+	}
+	sessions := []models.TableSession{}
+	selectQuery := QB.Select(tableSessionColumns...).From("table_sessions").Where("table_id = ?", id)
+	meta, ok := paginate(w, r, selectQuery, tableSessionListSpec, &sessions)
+	if !ok {
+		return
+	}
+	sendList(w, meta, sessions)
+}
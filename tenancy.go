@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// managedVendorIDs returns the vendors the user is listed for in vendor_admins
+func managedVendorIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	vendorIDs := []uuid.UUID{}
+	query, args, err := QB.Select("vendor_id").
+		From("vendor_admins").
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Select(&vendorIDs, query, args...); err != nil {
+		return nil, err
+	}
+	return vendorIDs, nil
+}
+
+// vendorScope returns the vendors whose rows the current user may see.
+// The boolean is false for admins, who are not restricted to any vendor.
+func vendorScope(r *http.Request) ([]uuid.UUID, bool, error) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		return []uuid.UUID{}, true, nil
+	}
+	if user.HasRole(models.RoleAdmin) {
+		return nil, false, nil
+	}
+	vendorIDs, err := managedVendorIDs(user.ID)
+	if err != nil {
+		return nil, true, err
+	}
+	return vendorIDs, true, nil
+}
+
+// canManageVendor reports whether the current user is an admin or one of the vendor's admins
+func canManageVendor(r *http.Request, vendorID uuid.UUID) (bool, error) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		return false, nil
+	}
+	if user.HasRole(models.RoleAdmin) {
+		return true, nil
+	}
+	query, args, err := QB.Select("1").
+		From("vendor_admins").
+		Where(squirrel.Eq{"user_id": user.ID, "vendor_id": vendorID}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	if err := db.Get(&exists, query, args...); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// authorizeVendor writes the error response and returns false when the current user
+// does not manage the given vendor
+func authorizeVendor(w http.ResponseWriter, r *http.Request, vendorID uuid.UUID) bool {
+	allowed, err := canManageVendor(r, vendorID)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor access: "+err.Error())
+		return false
+	}
+	if !allowed {
+		utils.HandelError(w, http.StatusForbidden, "You do not manage this vendor")
+		return false
+	}
+	return true
+}
+
+// authorizeVendorOf looks up the owning vendor with the given query, which must select a single
+// vendor_id, and checks it with authorizeVendor
+func authorizeVendorOf(w http.ResponseWriter, r *http.Request, lookup squirrel.SelectBuilder) bool {
+	query, args, err := lookup.ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return false
+	}
+	var vendorID uuid.UUID
+	if err := db.Get(&vendorID, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Resource not found")
+			return false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return authorizeVendor(w, r, vendorID)
+}
+
+// scopeByVendor restricts a query on a vendor-owned table to the vendors the current user manages.
+// Users holding only the customer role are left unrestricted so they can still browse every vendor.
+func scopeByVendor(w http.ResponseWriter, r *http.Request, query squirrel.SelectBuilder, column string) (squirrel.SelectBuilder, bool) {
+	user, _ := CurrentUser(r)
+	if !user.HasRole(models.RoleVendor) || user.HasRole(models.RoleAdmin) {
+		return query, true
+	}
+	vendorIDs, _, err := vendorScope(r)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor access: "+err.Error())
+		return query, false
+	}
+	return query.Where(squirrel.Eq{column: vendorIDs}), true
+}
+
+// orderVisibility limits orders to those placed by the current user or received by the vendors they manage
+func orderVisibility(r *http.Request) (squirrel.Sqlizer, error) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		return squirrel.Expr("1 = 0"), nil
+	}
+	vendorIDs, restricted, err := vendorScope(r)
+	if err != nil || !restricted {
+		return nil, err
+	}
+	return squirrel.Or{
+		squirrel.Eq{"orders.customer_id": user.ID},
+		squirrel.Eq{"orders.vendor_id": vendorIDs},
+	}, nil
+}
+
+// canViewOrder reports whether the current user placed the order or manages its vendor
+func canViewOrder(r *http.Request, order models.Order) (bool, error) {
+	if user, ok := CurrentUser(r); ok && user.ID == order.CustomerID {
+		return true, nil
+	}
+	return canManageVendor(r, order.VendorID)
+}
+
+// authorizeOrderAccess loads an order and writes the error response when the current user may not view it
+func authorizeOrderAccess(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) bool {
+	var order models.Order
+	query, args, err := QB.Select(strings.Join(order_columns, ", ")).From("orders").Where(squirrel.Eq{"id": orderID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if err := db.Get(&order, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Order not found")
+			return false
+		}
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	allowed, err := canViewOrder(r, order)
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if !allowed {
+		utils.HandelError(w, http.StatusForbidden, "You are not allowed to access this order")
+		return false
+	}
+	return true
+}
+
+// actingCustomer returns the customer a new order or cart belongs to: the current user, or for
+// admins the customer named by the given form field. Customers may only name themselves.
+func actingCustomer(w http.ResponseWriter, r *http.Request, field string) (uuid.UUID, bool) {
+	user, _ := CurrentUser(r)
+	value := r.FormValue(field)
+	if value == "" {
+		if user.HasRole(models.RoleAdmin) {
+			utils.HandelError(w, http.StatusBadRequest, field+" is required")
+			return uuid.Nil, false
+		}
+		return user.ID, true
+	}
+	customerID, err := uuid.Parse(value)
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid "+field+" format")
+		return uuid.Nil, false
+	}
+	if customerID != user.ID && !user.HasRole(models.RoleAdmin) {
+		utils.HandelError(w, http.StatusForbidden, "You can only act for yourself")
+		return uuid.Nil, false
+	}
+	return customerID, true
+}
@@ -1,54 +1,90 @@
-package controllers
-
-import (
-	"fmt"
-	"intership/models"
-	"intership/utils"
-	"net/http"
-	_ "os"
-	"strings"
-	"time"
-
-	_ "github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
-)
-
-func SignUpVendorHandler(w http.ResponseWriter, r *http.Request) {
-    vendor := models.Vendor{
-        ID:         uuid.New(),
-        Name:       r.FormValue("name"),
-        Description:      r.FormValue("description"),
-        Created_at: time.Now(),
-        Updated_at: time.Now(),
-    }
-    file, fileHeader, err := r.FormFile("img")
-    if err != nil && err != http.ErrMissingFile {
-        utils.HandelError(w, http.StatusBadRequest, "Invalid file")
-        return
-    } else if err == nil {
-        defer file.Close()
-        imageName, err := utils.SaveImageFile(file, "vendors", fileHeader.Filename)
-fmt.Println(imageName)
-        if err != nil {
-            utils.HandelError(w, http.StatusInternalServerError, "Error saving image")
-
-        }
-        vendor.Img = &imageName
-    }
-
-    query, args, err := QB.
-        Insert("vendors").Columns("id", "img", "name", "description").
-        Values(vendor.ID, vendor.Img, vendor.Name, vendor.Description).
-        Suffix(fmt.Sprintf("RETURNING %s", strings.Join(vendor_columns, ", "))).ToSql()
-    if err != nil {
-        utils.HandelError(w, http.StatusInternalServerError, "Error generate query ")
-        return
-    }
-    if err := db.QueryRowx(query, args...).StructScan(&vendor); err != nil {
-        utils.HandelError(w, http.StatusInternalServerError, "Error creating user"+err.Error())
-        return
-    }
-    utils.SendJSONResponse(w, http.StatusCreated, vendor)
-
-}
-
+package controllers
+
+import (
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	_ "os"
+	"strings"
+	"time"
+
+	_ "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+func SignUpVendorHandler(w http.ResponseWriter, r *http.Request) {
+    vendor := models.Vendor{
+        ID:         uuid.New(),
+        Name:       r.FormValue("name"),
+        Description:      r.FormValue("description"),
+        Currency:   models.DefaultCurrency,
+        Created_at: time.Now(),
+        Updated_at: time.Now(),
+    }
+    if r.FormValue("currency") != "" {
+        currency, ok := models.ParseCurrency(r.FormValue("currency"))
+        if !ok {
+            utils.HandelError(w, http.StatusBadRequest, "Unsupported currency "+r.FormValue("currency"))
+            return
+        }
+        vendor.Currency = currency
+    }
+    file, fileHeader, err := r.FormFile("img")
+    if err != nil && err != http.ErrMissingFile {
+        utils.HandelError(w, http.StatusBadRequest, "Invalid file")
+        return
+    } else if err == nil {
+        defer file.Close()
+        imageName, err := utils.SaveImageFile(file, "vendors", fileHeader.Filename)
+fmt.Println(imageName)
+        if err != nil {
+            utils.HandelError(w, http.StatusInternalServerError, "Error saving image")
+
+        }
+        vendor.Img = &imageName
+    }
+
+    query, args, err := QB.
+        Insert("vendors").Columns("id", "img", "name", "description", "currency").
+        Values(vendor.ID, vendor.Img, vendor.Name, vendor.Description, vendor.Currency).
+        Suffix(fmt.Sprintf("RETURNING %s", strings.Join(vendor_columns, ", "))).ToSql()
+    if err != nil {
+        utils.HandelError(w, http.StatusInternalServerError, "Error generate query ")
+        return
+    }
+    // The vendor and its first admin are saved together, a vendor nobody manages is unreachable
+    tx, err := db.Beginx()
+    if err != nil {
+        utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+        return
+    }
+    defer tx.Rollback()
+    if err := tx.QueryRowx(query, args...).StructScan(&vendor); err != nil {
+        utils.HandelError(w, http.StatusInternalServerError, "Error creating user"+err.Error())
+        return
+    }
+
+    // Vendors who register a store become its first admin so they can manage it
+    if user, ok := CurrentUser(r); ok && !user.HasRole(models.RoleAdmin) {
+        query, args, err = QB.Insert("vendor_admins").
+            Columns("user_id", "vendor_id").
+            Values(user.ID, vendor.ID).
+            ToSql()
+        if err != nil {
+            utils.HandelError(w, http.StatusInternalServerError, "Error building query")
+            return
+        }
+        if _, err := tx.Exec(query, args...); err != nil {
+            utils.HandelError(w, http.StatusInternalServerError, "Error linking vendor admin: "+err.Error())
+            return
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+        return
+    }
+    utils.SendJSONResponse(w, http.StatusCreated, vendor)
+
+}
+
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intership/models"
+	"intership/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // vendor timezones must resolve on hosts without a zoneinfo database
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var vendorHoursColumns = []string{
+	"id",
+	"vendor_id",
+	"weekday",
+	"to_char(opens_at, 'HH24:MI') AS opens_at",
+	"to_char(closes_at, 'HH24:MI') AS closes_at",
+}
+
+var vendorClosureColumns = []string{
+	"id",
+	"vendor_id",
+	"starts_on",
+	"ends_on",
+	"reason",
+	"created_at",
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+var (
+	hoursParam  = regexp.MustCompile(`^hours\[(\w+)\]$`)
+	hoursWindow = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d-([01]\d|2[0-3]):[0-5]\d$`)
+)
+
+// loadVendorSchedule fills the vendor's opening hours and its closures that have not ended yet
+func loadVendorSchedule(q sqlx.Queryer, vendor *models.Vendor) error {
+	vendor.Hours = []models.VendorHours{}
+	query, args, err := QB.Select(vendorHoursColumns...).
+		From("vendor_hours").
+		Where(squirrel.Eq{"vendor_id": vendor.ID}).
+		OrderBy("weekday", "opens_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := sqlx.Select(q, &vendor.Hours, query, args...); err != nil {
+		return err
+	}
+
+	vendor.Closures = []models.VendorClosure{}
+	query, args, err = QB.Select(vendorClosureColumns...).
+		From("vendor_closures").
+		Where(squirrel.Eq{"vendor_id": vendor.ID}).
+		Where("ends_on >= CURRENT_DATE - 1"). // a day behind, the vendor's timezone may still be on yesterday
+		OrderBy("starts_on").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	return sqlx.Select(q, &vendor.Closures, query, args...)
+}
+
+// vendorOpenAt evaluates the vendor's pause switch, closures and weekly hours at t.
+// A vendor without any opening hours configured is treated as always open.
+func vendorOpenAt(vendor models.Vendor, t time.Time) (bool, string) {
+	if vendor.IsPaused && (vendor.PausedUntil == nil || t.Before(*vendor.PausedUntil)) {
+		if vendor.PausedUntil != nil {
+			return false, fmt.Sprintf("%s has paused orders until %s", vendor.Name, vendor.PausedUntil.Format(time.RFC3339))
+		}
+		return false, vendor.Name + " has paused orders"
+	}
+
+	location, err := time.LoadLocation(vendor.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := t.In(location)
+
+	today := local.Format(time.DateOnly)
+	for _, closure := range vendor.Closures {
+		if closure.StartsOn.Format(time.DateOnly) <= today && today <= closure.EndsOn.Format(time.DateOnly) {
+			if closure.Reason != nil && *closure.Reason != "" {
+				return false, fmt.Sprintf("%s is closed today: %s", vendor.Name, *closure.Reason)
+			}
+			return false, vendor.Name + " is closed today"
+		}
+	}
+
+	if len(vendor.Hours) == 0 {
+		return true, ""
+	}
+	now := local.Format("15:04")
+	yesterday := (local.Weekday() + 6) % 7
+	for _, window := range vendor.Hours {
+		overnight := window.ClosesAt <= window.OpensAt
+		switch {
+		case window.Weekday == local.Weekday() && !overnight && window.OpensAt <= now && now < window.ClosesAt:
+			return true, ""
+		case window.Weekday == local.Weekday() && overnight && window.OpensAt <= now:
+			return true, ""
+		case window.Weekday == yesterday && overnight && now < window.ClosesAt:
+			return true, ""
+		}
+	}
+	return false, vendor.Name + " is closed at this time"
+}
+
+// loadScheduledVendor fetches a vendor together with its opening hours and closures
+func loadScheduledVendor(q sqlx.Queryer, vendorID uuid.UUID) (models.Vendor, error) {
+	var vendor models.Vendor
+	query, args, err := QB.Select(vendor_columns...).From("vendors").Where(squirrel.Eq{"id": vendorID}).ToSql()
+	if err != nil {
+		return vendor, err
+	}
+	if err := sqlx.Get(q, &vendor, query, args...); err != nil {
+		return vendor, err
+	}
+	return vendor, loadVendorSchedule(q, &vendor)
+}
+
+// vendorAcceptingOrders loads the vendor's schedule and reports whether it takes orders right now,
+// with the reason when it does not
+func vendorAcceptingOrders(q sqlx.Queryer, vendorID uuid.UUID) (bool, string, error) {
+	vendor, err := loadScheduledVendor(q, vendorID)
+	if err != nil {
+		return false, "", err
+	}
+	open, reason := vendorOpenAt(vendor, time.Now())
+	return open, reason, nil
+}
+
+// rejectClosedVendor answers 409 with the reason when the vendor is not taking orders right now
+func rejectClosedVendor(w http.ResponseWriter, q sqlx.Queryer, vendorID uuid.UUID) bool {
+	open, reason, err := vendorAcceptingOrders(q, vendorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandelError(w, http.StatusNotFound, "Vendor not found")
+			return true
+		}
+		utils.HandelError(w, http.StatusInternalServerError, "Error checking vendor hours: "+err.Error())
+		return true
+	}
+	if !open {
+		utils.HandelError(w, http.StatusConflict, reason)
+		return true
+	}
+	return false
+}
+
+// UpdateVendorHoursHandler handles PUT requests replacing a vendor's weekly opening hours.
+// Days left out are closed; several windows on a day are comma separated.
+//
+//	PUT /vendors/{id}/hours  hours[mon]=09:00-14:00,17:00-22:00  hours[fri]=18:00-02:00  timezone=Europe/Berlin
+func UpdateVendorHoursHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+
+	timezone := r.FormValue("timezone")
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Unknown timezone "+timezone)
+			return
+		}
+	}
+
+	var hours []models.VendorHours
+	for key, values := range r.Form {
+		match := hoursParam.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		weekday, ok := weekdays[strings.ToLower(match[1])]
+		if !ok {
+			utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("Unknown day %s, use sun, mon, tue, wed, thu, fri or sat", match[1]))
+			return
+		}
+		for _, value := range values {
+			for _, window := range strings.Split(value, ",") {
+				window = strings.TrimSpace(window)
+				if window == "" {
+					continue
+				}
+				if !hoursWindow.MatchString(window) {
+					utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("Invalid opening window %q, expected HH:MM-HH:MM", window))
+					return
+				}
+				opensAt, closesAt, _ := strings.Cut(window, "-")
+				if opensAt == closesAt {
+					utils.HandelError(w, http.StatusBadRequest, fmt.Sprintf("Opening window %q has no length", window))
+					return
+				}
+				hours = append(hours, models.VendorHours{ID: uuid.New(), VendorID: vendorID, Weekday: weekday, OpensAt: opensAt, ClosesAt: closesAt})
+			}
+		}
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error starting transaction: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	query, args, err := QB.Delete("vendor_hours").Where(squirrel.Eq{"vendor_id": vendorID}).ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error clearing opening hours: "+err.Error())
+		return
+	}
+	if len(hours) > 0 {
+		insert := QB.Insert("vendor_hours").Columns("id", "vendor_id", "weekday", "opens_at", "closes_at")
+		for _, window := range hours {
+			insert = insert.Values(window.ID, window.VendorID, int(window.Weekday), window.OpensAt, window.ClosesAt)
+		}
+		query, args, err = insert.ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error saving opening hours: "+err.Error())
+			return
+		}
+	}
+	if timezone != "" {
+		query, args, err = QB.Update("vendors").
+			Set("timezone", timezone).
+			Set("updated_at", time.Now()).
+			Where(squirrel.Eq{"id": vendorID}).
+			ToSql()
+		if err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+			return
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			utils.HandelError(w, http.StatusInternalServerError, "Error updating timezone: "+err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error committing transaction: "+err.Error())
+		return
+	}
+	showVendor(w, vendorID.String())
+}
+
+// CreateVendorClosureHandler handles POST requests adding days on which a vendor is closed
+func CreateVendorClosureHandler(w http.ResponseWriter, r *http.Request) {
+	var closure models.VendorClosure
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+	if r.FormValue("starts_on") == "" {
+		utils.HandelError(w, http.StatusBadRequest, "starts_on is required")
+		return
+	}
+
+	closure.ID = uuid.New()
+	closure.VendorID = vendorID
+	closure.StartsOn, err = time.Parse(time.DateOnly, r.FormValue("starts_on"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid starts_on format, expected YYYY-MM-DD")
+		return
+	}
+	closure.EndsOn = closure.StartsOn
+	if r.FormValue("ends_on") != "" {
+		closure.EndsOn, err = time.Parse(time.DateOnly, r.FormValue("ends_on"))
+		if err != nil {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid ends_on format, expected YYYY-MM-DD")
+			return
+		}
+	}
+	if closure.EndsOn.Before(closure.StartsOn) {
+		utils.HandelError(w, http.StatusBadRequest, "ends_on cannot be before starts_on")
+		return
+	}
+	if reason := r.FormValue("reason"); reason != "" {
+		closure.Reason = &reason
+	}
+
+	query, args, err := QB.Insert("vendor_closures").
+		Columns("id", "vendor_id", "starts_on", "ends_on", "reason").
+		Values(closure.ID, closure.VendorID, closure.StartsOn.Format(time.DateOnly), closure.EndsOn.Format(time.DateOnly), closure.Reason).
+		Suffix(fmt.Sprintf("RETURNING %s", strings.Join(vendorClosureColumns, ", "))).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if err := db.QueryRowx(query, args...).StructScan(&closure); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error creating closure: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, closure)
+}
+
+// DeleteVendorClosureHandler handles DELETE requests removing a vendor closure
+func DeleteVendorClosureHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+
+	query, args, err := QB.Delete("vendor_closures").
+		Where(squirrel.Eq{"id": r.PathValue("closure_id"), "vendor_id": vendorID}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error deleting closure: "+err.Error())
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, "Closure deleted")
+}
+
+// PauseVendorHandler handles POST requests that stop a vendor taking orders,
+// indefinitely or for the given number of minutes
+func PauseVendorHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+
+	var pausedUntil *time.Time
+	if r.FormValue("minutes") != "" {
+		minutes, err := strconv.Atoi(r.FormValue("minutes"))
+		if err != nil || minutes <= 0 {
+			utils.HandelError(w, http.StatusBadRequest, "Invalid minutes value")
+			return
+		}
+		until := time.Now().Add(time.Duration(minutes) * time.Minute)
+		pausedUntil = &until
+	}
+	setVendorPause(w, vendorID, true, pausedUntil)
+}
+
+// ResumeVendorHandler handles POST requests that lift a vendor's pause
+func ResumeVendorHandler(w http.ResponseWriter, r *http.Request) {
+	vendorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.HandelError(w, http.StatusBadRequest, "Invalid vendor id format")
+		return
+	}
+	if !authorizeVendor(w, r, vendorID) {
+		return
+	}
+	setVendorPause(w, vendorID, false, nil)
+}
+
+func setVendorPause(w http.ResponseWriter, vendorID uuid.UUID, paused bool, until *time.Time) {
+	query, args, err := QB.Update("vendors").
+		Set("is_paused", paused).
+		Set("paused_until", until).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": vendorID}).
+		ToSql()
+	if err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error building query: "+err.Error())
+		return
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		utils.HandelError(w, http.StatusInternalServerError, "Error updating vendor: "+err.Error())
+		return
+	}
+	showVendor(w, vendorID.String())
+}